@@ -0,0 +1,80 @@
+package postgrest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// BearerTokenSource supplies pre-signed tokens, e.g: the token of an end user, sent verbatim to postgREST
+type BearerTokenSource interface {
+	BearerToken() (string, error)
+}
+
+// StaticBearerToken is a BearerTokenSource always returning the same token
+type StaticBearerToken string
+
+// BearerToken returns the token
+func (t StaticBearerToken) BearerToken() (string, error) {
+	return string(t), nil
+}
+
+// BearerTokenFunc is a function used as a BearerTokenSource
+type BearerTokenFunc func() (string, error)
+
+// BearerToken calls f
+func (f BearerTokenFunc) BearerToken() (string, error) {
+	return f()
+}
+
+// WithBearerToken returns a copy of the agent authorizing its requests, reads and writes alike,
+// with the tokens supplied by source instead of signing its own.
+// Tokens may carry a "Bearer " prefix and are rejected before sending when their exp claim has passed.
+func (agent *Agent) WithBearerToken(source BearerTokenSource) *Agent {
+	scoped := *agent
+	scoped.bearer = source
+	return &scoped
+}
+
+// bearerTokenStr returns the authentication string for the token supplied by the agent's bearer token source
+func (agent *Agent) bearerTokenStr() (string, error) {
+	token, err := agent.bearer.BearerToken()
+	if err != nil {
+		return "", err
+	}
+	token = strings.TrimSpace(strings.TrimPrefix(token, "Bearer "))
+	if err := validateBearerToken(token, time.Now()); err != nil {
+		return "", err
+	}
+	return "Bearer " + token, nil
+}
+
+// validateBearerToken ensures that token is a JSON Web Token whose exp claim, if any, is after now
+func validateBearerToken(token string, now time.Time) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return errMalformedBearerToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return errMalformedBearerToken
+	}
+	var claims struct {
+		ExpiresAt *json.Number `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return errMalformedBearerToken
+	}
+	if claims.ExpiresAt == nil {
+		return nil
+	}
+	expiresAt, err := claims.ExpiresAt.Float64()
+	if err != nil {
+		return errMalformedBearerToken
+	}
+	if expiresAt <= float64(now.Unix()) {
+		return errExpiredBearerToken
+	}
+	return nil
+}
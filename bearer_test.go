@@ -0,0 +1,82 @@
+package postgrest
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// unsignedToken returns a token with the given payload and a dummy header and signature
+func unsignedToken(payload string) string {
+	return "eyJhbGciOiJIUzI1NiJ9." + base64.RawURLEncoding.EncodeToString([]byte(payload)) + ".c2ln"
+}
+
+func TestValidateBearerToken(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1000, 0)
+	var tests = []struct {
+		token    string
+		expected error
+	}{
+		{unsignedToken(`{"role":"web_user","exp":1001}`), nil},
+		{unsignedToken(`{"role":"web_user"}`), nil},
+		{unsignedToken(`{"exp":1000}`), errExpiredBearerToken},
+		{unsignedToken(`{"exp":999.5}`), errExpiredBearerToken},
+		{unsignedToken(`{"exp":"soon"}`), errMalformedBearerToken},
+		{unsignedToken(`[]`), errMalformedBearerToken},
+		{"eyJhbGciOiJIUzI1NiJ9.!.c2ln", errMalformedBearerToken},
+		{"token", errMalformedBearerToken},
+		{"", errMalformedBearerToken},
+	}
+	for _, test := range tests {
+		if err := validateBearerToken(test.token, now); err != test.expected {
+			t.Errorf("validateBearerToken returned unexpected error for %s:\nExpected: %v\nGot: %v", test.token, test.expected, err)
+		}
+	}
+}
+
+func TestWithBearerToken(t *testing.T) {
+	t.Parallel()
+
+	var authorizations []string
+	bearerServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorizations = append(authorizations, r.Header.Get("Authorization"))
+		w.Write([]byte("[]"))
+	}))
+	defer bearerServer.Close()
+	testAgent := newTestAgent(bearerServer.URL)
+
+	valid := unsignedToken(`{"role":"web_user","exp":4102444800}`)
+	userAgent := testAgent.WithBearerToken(StaticBearerToken("Bearer " + valid))
+	userAgent.Get("test_table", nil)
+	userAgent.PatchJSON("test_table", nil, map[string]string{})
+	testAgent.Impersonate("other", nil).WithBearerToken(BearerTokenFunc(func() (string, error) { return valid, nil })).Get("test_table", nil)
+	testAgent.Get("test_table", nil)
+
+	expected := []string{"Bearer " + valid, "Bearer " + valid, "Bearer " + valid, "Bearer secret"}
+	if len(authorizations) != len(expected) {
+		t.Fatalf("agent made unexpected number of requests:\nExpected: %d\nGot: %d", len(expected), len(authorizations))
+	}
+	for i := range expected {
+		if authorizations[i] != expected[i] {
+			t.Errorf("agent sent unexpected Authorization header:\nExpected: %s\nGot: %s", expected[i], authorizations[i])
+		}
+	}
+
+	expired := testAgent.WithBearerToken(StaticBearerToken(unsignedToken(`{"exp":1}`)))
+	if _, err := expired.Get("test_table", nil); err != errExpiredBearerToken {
+		t.Errorf("Get returned unexpected error:\nExpected: %v\nGot: %v", errExpiredBearerToken, err)
+	}
+	errSource := errors.New("source error")
+	failing := testAgent.WithBearerToken(BearerTokenFunc(func() (string, error) { return "", errSource }))
+	if _, err := failing.Get("test_table", nil); err != errSource {
+		t.Errorf("Get returned unexpected error:\nExpected: %v\nGot: %v", errSource, err)
+	}
+	if len(authorizations) != len(expected) {
+		t.Errorf("agent sent requests with invalid bearer tokens:\nExpected: %d\nGot: %d", len(expected), len(authorizations))
+	}
+}
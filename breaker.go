@@ -0,0 +1,154 @@
+package postgrest
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrCircuitOpen is returned for requests short-circuited by the open circuit breaker of their endpoint
+var ErrCircuitOpen = errors.New("postgrest error: circuit breaker is open")
+
+// BreakerState is the state of the circuit breaker of an endpoint
+type BreakerState int
+
+// circuit breaker states
+const (
+	// BreakerClosed lets all requests through
+	BreakerClosed BreakerState = iota
+	// BreakerOpen short-circuits all requests until the cool-down has elapsed
+	BreakerOpen
+	// BreakerHalfOpen lets a limited number of trial requests through
+	BreakerHalfOpen
+)
+
+// String returns the name of the state
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// BreakerSettings configures the circuit breakers of the master and replica endpoints.
+// A breaker opens after FailureThreshold consecutive connection errors or 5xx responses and short-circuits
+// requests with ErrCircuitOpen. After CoolDown it lets HalfOpenRequests trial requests through,
+// closing when they all succeed and opening again on the first failure.
+type BreakerSettings struct {
+	// FailureThreshold is the number of consecutive failures opening the breaker (5 by default)
+	FailureThreshold int
+	// CoolDown is the duration the breaker stays open (30s by default)
+	CoolDown time.Duration
+	// HalfOpenRequests is the number of trial requests of a half-open breaker (1 by default)
+	HalfOpenRequests int
+	// OnStateChange is called with the base url of the endpoint when its breaker changes state
+	OnStateChange func(baseURL string, from, to BreakerState)
+}
+
+// newBreakerSettings returns a copy of settings with the defaults of its unset fields, or nil when settings is nil
+func newBreakerSettings(settings *BreakerSettings) *BreakerSettings {
+	if settings == nil {
+		return nil
+	}
+	s := *settings
+	if s.FailureThreshold <= 0 {
+		s.FailureThreshold = 5
+	}
+	if s.CoolDown <= 0 {
+		s.CoolDown = 30 * time.Second
+	}
+	if s.HalfOpenRequests <= 0 {
+		s.HalfOpenRequests = 1
+	}
+	return &s
+}
+
+// State returns the state of the circuit breaker of the endpoint
+func (e *Endpoint) State() BreakerState {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.state
+}
+
+// allow returns ErrCircuitOpen if the circuit breaker of the endpoint short-circuits the request
+func (e *Endpoint) allow() error {
+	e.mu.Lock()
+	if e.breaker == nil {
+		e.mu.Unlock()
+		return nil
+	}
+	notify := func() {}
+	if e.state == BreakerOpen && !time.Now().Before(e.openedAt.Add(e.breaker.CoolDown)) {
+		notify = e.transition(BreakerHalfOpen)
+	}
+	var err error
+	switch e.state {
+	case BreakerOpen:
+		err = ErrCircuitOpen
+	case BreakerHalfOpen:
+		if e.trials >= e.breaker.HalfOpenRequests {
+			err = ErrCircuitOpen
+		} else {
+			e.trials++
+		}
+	}
+	e.mu.Unlock()
+	notify()
+	return err
+}
+
+// recordBreaker records the outcome of a request in the circuit breaker and returns the function notifying
+// its state change. It must be called with the endpoint locked.
+func (e *Endpoint) recordBreaker(failed bool) func() {
+	if e.breaker == nil {
+		return func() {}
+	}
+	switch e.state {
+	case BreakerClosed:
+		if !failed {
+			e.breakerFailures = 0
+			break
+		}
+		if e.breakerFailures++; e.breakerFailures >= e.breaker.FailureThreshold {
+			return e.transition(BreakerOpen)
+		}
+	case BreakerHalfOpen:
+		if failed {
+			return e.transition(BreakerOpen)
+		}
+		if e.successes++; e.successes >= e.breaker.HalfOpenRequests {
+			return e.transition(BreakerClosed)
+		}
+	}
+	return func() {}
+}
+
+// abandon releases the trial of a request whose outcome is unknown, e.g: canceled by its context
+func (e *Endpoint) abandon() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.state == BreakerHalfOpen && e.trials > 0 {
+		e.trials--
+	}
+}
+
+// transition sets the state of the circuit breaker and returns the function notifying the change.
+// It must be called with the endpoint locked.
+func (e *Endpoint) transition(to BreakerState) func() {
+	from := e.state
+	e.state, e.breakerFailures, e.trials, e.successes = to, 0, 0, 0
+	if to == BreakerOpen {
+		e.openedAt = time.Now()
+	}
+	onStateChange, baseURL := e.breaker.OnStateChange, e.baseURL
+	if onStateChange == nil || from == to {
+		return func() {}
+	}
+	return func() {
+		onStateChange(baseURL, from, to)
+	}
+}
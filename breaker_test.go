@@ -0,0 +1,145 @@
+package postgrest
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBreakerState(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var transitions []string
+	endpoint := newEndpoint("a", 1, false, &Config{
+		EjectionThreshold: 100,
+		CircuitBreaker: &BreakerSettings{
+			FailureThreshold: 2,
+			CoolDown:         20 * time.Millisecond,
+			HalfOpenRequests: 2,
+			OnStateChange: func(baseURL string, from, to BreakerState) {
+				mu.Lock()
+				defer mu.Unlock()
+				transitions = append(transitions, baseURL+":"+from.String()+"->"+to.String())
+			},
+		},
+	})
+
+	endpoint.report(true)
+	endpoint.report(false)
+	endpoint.report(true)
+	if endpoint.State() != BreakerClosed || endpoint.allow() != nil {
+		t.Errorf("breaker opened without consecutive failures: %s", endpoint.State())
+	}
+	endpoint.report(true)
+	if endpoint.State() != BreakerOpen || endpoint.allow() != ErrCircuitOpen || endpoint.healthy(time.Now()) {
+		t.Errorf("breaker did not open after consecutive failures: %s", endpoint.State())
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if !endpoint.healthy(time.Now()) {
+		t.Error("breaker short-circuits requests after its cool-down")
+	}
+	if endpoint.allow() != nil || endpoint.allow() != nil || endpoint.allow() != ErrCircuitOpen {
+		t.Error("half-open breaker allowed unexpected number of trials")
+	}
+	endpoint.abandon()
+	if endpoint.allow() != nil {
+		t.Error("half-open breaker did not release an abandoned trial")
+	}
+	endpoint.report(false)
+	endpoint.report(true)
+	if endpoint.State() != BreakerOpen {
+		t.Errorf("half-open breaker did not open after a failed trial: %s", endpoint.State())
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	endpoint.allow()
+	endpoint.allow()
+	endpoint.report(false)
+	endpoint.report(false)
+	if endpoint.State() != BreakerClosed {
+		t.Errorf("half-open breaker did not close after successful trials: %s", endpoint.State())
+	}
+
+	expected := "a:closed->open,a:open->half-open,a:half-open->open,a:open->half-open,a:half-open->closed"
+	mu.Lock()
+	defer mu.Unlock()
+	if strings.Join(transitions, ",") != expected {
+		t.Errorf("breaker reported unexpected transitions:\nExpected: %s\nGot: %s", expected, strings.Join(transitions, ","))
+	}
+}
+
+func TestConfigCircuitBreaker(t *testing.T) {
+	t.Parallel()
+
+	servers := newReplicaServers("master", "slave", "replica")
+	defer servers.close()
+	generator := func(_ interface{}, secret string) (string, error) { return secret, nil }
+	testAgent, _ := NewAgent(&Config{
+		MasterBaseURL:  servers.servers["master"].URL,
+		MasterRole:     "master",
+		MasterSecret:   "master_secret",
+		SlaveBaseURL:   servers.servers["slave"].URL,
+		SlaveRole:      "slave",
+		SlaveSecret:    "slave_secret",
+		Timeout:        5,
+		Replicas:       []Replica{{BaseURL: servers.servers["replica"].URL}},
+		CircuitBreaker: &BreakerSettings{FailureThreshold: 2, CoolDown: time.Hour},
+	}, &http.Client{}, generator)
+
+	servers.setFailing("master", true)
+	for i := 0; i < 4; i++ {
+		testAgent.PostJSON("test_table", map[string]string{}, nil)
+	}
+	if count := servers.count("master POST Bearer master_secret"); count != 2 {
+		t.Errorf("agent sent requests through an open breaker:\nExpected: %d\nGot: %d", 2, count)
+	}
+	_, err := testAgent.Impersonate("user", nil).PostJSON("test_table", map[string]string{}, nil)
+	if err != ErrCircuitOpen || !IsCircuitOpen(err) {
+		t.Errorf("PostJSON returned unexpected error:\nExpected: %v\nGot: %v", ErrCircuitOpen, err)
+	}
+
+	servers.setFailing("replica", true)
+	for i := 0; i < 8; i++ {
+		testAgent.GetJSON("test_table", nil, nil)
+	}
+	if count := servers.count("replica GET Bearer slave_secret"); count != 2 {
+		t.Errorf("agent sent reads to a replica with an open breaker:\nExpected: %d\nGot: %d", 2, count)
+	}
+}
+
+func TestHalfOpenReplica(t *testing.T) {
+	t.Parallel()
+
+	servers := newReplicaServers("slave", "replica")
+	defer servers.close()
+	generator := func(_ interface{}, secret string) (string, error) { return secret, nil }
+	testAgent, _ := NewAgent(&Config{
+		MasterBaseURL:  servers.servers["slave"].URL,
+		MasterRole:     "master",
+		MasterSecret:   "master_secret",
+		SlaveBaseURL:   servers.servers["slave"].URL,
+		SlaveRole:      "slave",
+		SlaveSecret:    "slave_secret",
+		Timeout:        5,
+		Replicas:       []Replica{{BaseURL: servers.servers["replica"].URL}},
+		RetryPolicy:    &RetryPolicy{MaxAttempts: 1},
+		CircuitBreaker: &BreakerSettings{HalfOpenRequests: 1},
+	}, &http.Client{}, generator)
+
+	halfOpen := testAgent.replicas.endpoints[1]
+	halfOpen.mu.Lock()
+	halfOpen.state, halfOpen.trials = BreakerHalfOpen, 1
+	halfOpen.mu.Unlock()
+	for i := 0; i < 10; i++ {
+		if _, err := testAgent.GetJSON("test_table", nil, nil); err != nil {
+			t.Errorf("GetJSON returned unexpected error: %v", err)
+		}
+	}
+	if count := servers.count("slave GET Bearer slave_secret"); count != 10 {
+		t.Errorf("agent sent unexpected number of reads to the healthy replica:\nExpected: %d\nGot: %d", 10, count)
+	}
+}
@@ -0,0 +1,333 @@
+package postgrest
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CachedResponse is a successful response stored in a ResponseCache
+type CachedResponse struct {
+	Header   http.Header
+	Body     []byte
+	StoredAt time.Time
+	// Expires is the time until which the response is fresh, after which it is revalidated when it has
+	// an ETag or a Last-Modified header. It is zero when the response does not specify its freshness.
+	Expires time.Time
+}
+
+// ResponseCache stores the successful responses of GET requests by url, headers, role and extra claims.
+// Cached responses are served while fresh, according to their Cache-Control max-age or Expires header or else
+// the default of the cache, and then revalidated with If-None-Match and If-Modified-Since when the server
+// provided an ETag or a Last-Modified header. Responses with Cache-Control no-store are not stored, responses
+// with no-cache are always revalidated and requests with no-store or no-cache bypass or revalidate the cache.
+// Reads made within the write window of a Session are not served from the cache.
+// Writes made through the agent, or the agents derived from it, invalidate the cached reads of their table,
+// and function calls invalidate all cached reads. Reads embedding the written table are not invalidated.
+// Implementations must be safe for concurrent use and should set the Expires of the responses without one,
+// e.g: to a default TTL. Stored responses must not be modified.
+type ResponseCache interface {
+	Get(key string) (*CachedResponse, bool)
+	Set(key string, response *CachedResponse)
+	Delete(key string)
+}
+
+// responseCache is the response cache of an agent with its statistics.
+// The keys of its responses contain the generations of their path, which writes increment to invalidate them.
+type responseCache struct {
+	hits        uint64
+	revalidated uint64
+	cache       ResponseCache
+	mu          sync.Mutex
+	generation  uint64
+	generations map[string]uint64
+}
+
+// newResponseCache returns the response cache of an agent storing responses in cache, or nil when cache is nil
+func newResponseCache(cache ResponseCache) *responseCache {
+	if cache == nil {
+		return nil
+	}
+	return &responseCache{cache: cache, generations: map[string]uint64{}}
+}
+
+// generationOf returns the generation of the cached reads of path
+func (c *responseCache) generationOf(path string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return strconv.FormatUint(c.generation, 10) + "." + strconv.FormatUint(c.generations[path], 10)
+}
+
+// invalidate discards the cached reads of path after a write, or all cached reads after a function call
+func (c *responseCache) invalidate(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if strings.HasPrefix(path, "rpc/") {
+		c.generation++
+		c.generations = map[string]uint64{}
+		return
+	}
+	c.generations[path]++
+}
+
+// CacheStats contains the number of reads served from the response cache, including the revalidated ones
+type CacheStats struct {
+	Hits        uint64
+	Revalidated uint64
+}
+
+// CacheStats returns the number of reads served from the response cache
+func (agent *Agent) CacheStats() CacheStats {
+	if agent.cache == nil {
+		return CacheStats{}
+	}
+	return CacheStats{Hits: atomic.LoadUint64(&agent.cache.hits), Revalidated: atomic.LoadUint64(&agent.cache.revalidated)}
+}
+
+// cached serves the read from the response cache when fresh and otherwise sends it, conditionally when
+// the cached response has validators, and stores its response
+func (agent *Agent) cached(ctx context.Context, c *call) (*http.Response, error) {
+	if session := SessionFromContext(ctx); session != nil && session.readsFromMaster() {
+		return agent.readThrough(ctx, c)
+	}
+	directives := parseCacheControl(c.header.Get("Cache-Control"))
+	if _, ok := directives["no-store"]; ok {
+		return agent.readThrough(ctx, c)
+	}
+	key, err := agent.cacheKey(c)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	stored, ok := agent.cache.cache.Get(key)
+	_, noCache := directives["no-cache"]
+	if ok && !noCache && now.Before(stored.Expires) {
+		atomic.AddUint64(&agent.cache.hits, 1)
+		return stored.response(), nil
+	}
+	conditional := c
+	if ok && stored.hasValidators() {
+		copied := *c
+		copied.header = cloneHeader(c.header)
+		if etag := stored.Header.Get("ETag"); etag != "" {
+			copied.header.Set("If-None-Match", etag)
+		}
+		if lastModified := stored.Header.Get("Last-Modified"); lastModified != "" {
+			copied.header.Set("If-Modified-Since", lastModified)
+		}
+		conditional = &copied
+	}
+
+	response, err := agent.readThrough(ctx, conditional)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode == http.StatusNotModified && conditional != c {
+		response.Body.Close()
+		refreshed := &CachedResponse{Header: cloneHeader(stored.Header), Body: stored.Body, StoredAt: now}
+		for name, values := range response.Header {
+			refreshed.Header[name] = values
+		}
+		refreshed.Expires = expiry(refreshed.Header, now)
+		agent.cache.cache.Set(key, refreshed)
+		atomic.AddUint64(&agent.cache.revalidated, 1)
+		return refreshed.response(), nil
+	}
+	if response.StatusCode != http.StatusOK {
+		return response, nil
+	}
+	if _, ok := parseCacheControl(response.Header.Get("Cache-Control"))["no-store"]; ok {
+		agent.cache.cache.Delete(key)
+		return response, nil
+	}
+
+	body, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	stored = &CachedResponse{Header: cloneHeader(response.Header), Body: body, StoredAt: now}
+	stored.Expires = expiry(stored.Header, now)
+	if stored.Expires.IsZero() || stored.Expires.After(now) || stored.hasValidators() {
+		agent.cache.cache.Set(key, stored)
+	}
+	response.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return response, nil
+}
+
+// cacheKey returns the key of the read in the response cache, made of the generation of its path, its url,
+// headers and the role and extra claims, or bearer token, the read is authorized with
+func (agent *Agent) cacheKey(c *call) (string, error) {
+	identity := ""
+	if agent.bearer != nil {
+		tokenStr, err := agent.bearerTokenStr()
+		if err != nil {
+			return "", err
+		}
+		identity = tokenStr
+	} else {
+		role, _, scopeKey, err := agent.scopedRole(agent.config.SlaveRole)
+		if err != nil {
+			return "", err
+		}
+		identity = role + "\n" + scopeKey
+	}
+	key := bytes.NewBufferString(agent.cache.generationOf(c.path) + "\n" + c.path)
+	if c.query != nil {
+		key.WriteString("?" + c.query.Encode())
+	}
+	key.WriteString("\n" + identity + "\n")
+	c.header.Write(key)
+	return key.String(), nil
+}
+
+// hasValidators returns true if the response can be revalidated
+func (r *CachedResponse) hasValidators() bool {
+	return r.Header.Get("ETag") != "" || r.Header.Get("Last-Modified") != ""
+}
+
+// response returns a 200 OK http.Response for the cached response
+func (r *CachedResponse) response() *http.Response {
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        cloneHeader(r.Header),
+		Body:          ioutil.NopCloser(bytes.NewReader(r.Body)),
+		ContentLength: int64(len(r.Body)),
+	}
+}
+
+// expiry returns the time until which a response received at now is fresh according to its headers,
+// or zero if they do not specify it
+func expiry(header http.Header, now time.Time) time.Time {
+	directives := parseCacheControl(header.Get("Cache-Control"))
+	if _, ok := directives["no-cache"]; ok {
+		return now
+	}
+	if maxAge, ok := directives["max-age"]; ok {
+		if seconds, err := strconv.Atoi(maxAge); err == nil {
+			return now.Add(time.Duration(seconds) * time.Second)
+		}
+		return now
+	}
+	if expires := header.Get("Expires"); expires != "" {
+		if date, err := http.ParseTime(expires); err == nil {
+			return date
+		}
+		return now
+	}
+	return time.Time{}
+}
+
+// parseCacheControl returns the directives of a Cache-Control header by name
+func parseCacheControl(value string) map[string]string {
+	directives := map[string]string{}
+	for _, directive := range strings.Split(value, ",") {
+		directive = strings.TrimSpace(directive)
+		if directive == "" {
+			continue
+		}
+		name, arg := directive, ""
+		if i := strings.Index(directive, "="); i >= 0 {
+			name, arg = directive[:i], strings.Trim(directive[i+1:], `"`)
+		}
+		directives[strings.ToLower(strings.TrimSpace(name))] = arg
+	}
+	return directives
+}
+
+// cloneHeader returns a copy of header
+func cloneHeader(header http.Header) http.Header {
+	cloned := make(http.Header, len(header))
+	for name, values := range header {
+		cloned[name] = append([]string{}, values...)
+	}
+	return cloned
+}
+
+// lruCache is an in-memory ResponseCache evicting the least recently used responses
+type lruCache struct {
+	size    int
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries *list.List
+	items   map[string]*list.Element
+}
+
+// lruEntry is a response stored in an lruCache
+type lruEntry struct {
+	key      string
+	response *CachedResponse
+}
+
+// NewLRUCache returns an in-memory ResponseCache holding up to size responses, fresh for ttl unless their
+// headers specify otherwise. Stale responses without validators are evicted when read.
+func NewLRUCache(size int, ttl time.Duration) ResponseCache {
+	if size <= 0 {
+		size = 1
+	}
+	return &lruCache{size: size, ttl: ttl, entries: list.New(), items: map[string]*list.Element{}}
+}
+
+// Get returns the response stored for key
+func (c *lruCache) Get(key string) (*CachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	response := element.Value.(*lruEntry).response
+	if !time.Now().Before(response.Expires) && !response.hasValidators() {
+		c.remove(element)
+		return nil, false
+	}
+	c.entries.MoveToFront(element)
+	return response, true
+}
+
+// Set stores the response for key, fresh for the ttl of the cache when it has no Expires
+func (c *lruCache) Set(key string, response *CachedResponse) {
+	if response.Expires.IsZero() {
+		copied := *response
+		copied.Expires = copied.StoredAt.Add(c.ttl)
+		response = &copied
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.items[key]; ok {
+		element.Value.(*lruEntry).response = response
+		c.entries.MoveToFront(element)
+		return
+	}
+	c.items[key] = c.entries.PushFront(&lruEntry{key: key, response: response})
+	for c.entries.Len() > c.size {
+		c.remove(c.entries.Back())
+	}
+}
+
+// Delete removes the response stored for key
+func (c *lruCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.items[key]; ok {
+		c.remove(element)
+	}
+}
+
+// remove removes an element of the cache. It must be called with the cache locked.
+func (c *lruCache) remove(element *list.Element) {
+	c.entries.Remove(element)
+	delete(c.items, element.Value.(*lruEntry).key)
+}
@@ -0,0 +1,230 @@
+package postgrest
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLRUCache(t *testing.T) {
+	t.Parallel()
+
+	cache := NewLRUCache(2, time.Minute)
+	now := time.Now()
+	cache.Set("a", &CachedResponse{Body: []byte("a"), StoredAt: now})
+	cache.Set("b", &CachedResponse{Body: []byte("b"), StoredAt: now})
+	cache.Get("a")
+	cache.Set("c", &CachedResponse{Body: []byte("c"), StoredAt: now})
+	if _, ok := cache.Get("b"); ok {
+		t.Error("LRUCache did not evict the least recently used response")
+	}
+	if response, ok := cache.Get("a"); !ok || !response.Expires.Equal(now.Add(time.Minute)) {
+		t.Errorf("LRUCache returned unexpected response for a: %v %v", response, ok)
+	}
+
+	cache.Set("c", &CachedResponse{Header: http.Header{}, StoredAt: now.Add(-2 * time.Minute)})
+	if _, ok := cache.Get("c"); ok {
+		t.Error("LRUCache returned a stale response without validators")
+	}
+	stale := &CachedResponse{Header: http.Header{"Etag": {`"v1"`}}, StoredAt: now.Add(-2 * time.Minute)}
+	cache.Set("d", stale)
+	if _, ok := cache.Get("d"); !ok {
+		t.Error("LRUCache did not return a stale response with validators")
+	}
+	cache.Delete("d")
+	if _, ok := cache.Get("d"); ok {
+		t.Error("LRUCache returned a deleted response")
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	var tests = []struct {
+		header   http.Header
+		expected time.Time
+	}{
+		{http.Header{}, time.Time{}},
+		{http.Header{"Cache-Control": {"public, max-age=60"}}, now.Add(time.Minute)},
+		{http.Header{"Cache-Control": {"max-age=60, no-cache"}}, now},
+		{http.Header{"Cache-Control": {"max-age=invalid"}}, now},
+		{http.Header{"Expires": {"Sun, 01 Jan 2017 01:00:00 GMT"}}, now.Add(time.Hour)},
+		{http.Header{"Cache-Control": {"max-age=10"}, "Expires": {"Sun, 01 Jan 2017 01:00:00 GMT"}}, now.Add(10 * time.Second)},
+		{http.Header{"Expires": {"0"}}, now},
+	}
+	for _, test := range tests {
+		if expires := expiry(test.header, now); !expires.Equal(test.expected) {
+			t.Errorf("expiry returned unexpected time for %v:\nExpected: %v\nGot: %v", test.header, test.expected, expires)
+		}
+	}
+}
+
+// cacheServer serves a body per path with the headers of the path, answering conditional requests
+// matching its ETag or Last-Modified with 304 Not Modified
+type cacheServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	headers  map[string]http.Header
+	requests map[string]int
+}
+
+func newCacheServer() *cacheServer {
+	s := &cacheServer{headers: map[string]http.Header{}, requests: map[string]int{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		header := s.headers[r.URL.Path]
+		key := r.URL.Path + " " + r.Header.Get("Authorization")
+		if r.Header.Get("If-None-Match") != "" || r.Header.Get("If-Modified-Since") != "" {
+			key += " conditional"
+		}
+		s.requests[key]++
+		s.mu.Unlock()
+		for name, values := range header {
+			w.Header()[name] = values
+		}
+		if etag := header.Get("ETag"); etag != "" && r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		if lastModified := header.Get("Last-Modified"); lastModified != "" && r.Header.Get("If-Modified-Since") == lastModified {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte(r.URL.Path))
+	}))
+	return s
+}
+
+func (s *cacheServer) count(key string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[key]
+}
+
+func TestResponseCache(t *testing.T) {
+	t.Parallel()
+
+	server := newCacheServer()
+	defer server.Close()
+	server.headers["/fresh"] = http.Header{"Cache-Control": {"max-age=60"}}
+	server.headers["/no_store"] = http.Header{"Cache-Control": {"no-store"}}
+	server.headers["/etag"] = http.Header{"Cache-Control": {"no-cache"}, "Etag": {`"v1"`}}
+	server.headers["/last_modified"] = http.Header{"Last-Modified": {"Sun, 01 Jan 2017 00:00:00 GMT"}}
+
+	generator := func(claims interface{}, secret string) (string, error) { return claims.(*Claims).Role, nil }
+	testAgent, _ := NewAgent(&Config{
+		MasterBaseURL: server.URL,
+		MasterRole:    "master",
+		MasterSecret:  "master_secret",
+		SlaveBaseURL:  server.URL,
+		SlaveRole:     "slave",
+		SlaveSecret:   "slave_secret",
+		Timeout:       5,
+		ResponseCache: NewLRUCache(16, 0),
+	}, &http.Client{}, generator)
+
+	get := func(agent *Agent, ctx context.Context, table string) {
+		response, err := agent.send(ctx, http.MethodGet, table, nil, nil, nil)
+		if err != nil {
+			t.Fatalf("send returned unexpected error for %s: %v", table, err)
+		}
+		body, _ := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+		if response.StatusCode != http.StatusOK || string(body) != "/"+table {
+			t.Errorf("send returned unexpected response for %s:\nExpected: %d %s\nGot: %d %s", table, http.StatusOK, "/"+table, response.StatusCode, body)
+		}
+	}
+	for _, table := range []string{"fresh", "no_store", "etag", "last_modified"} {
+		for i := 0; i < 3; i++ {
+			get(testAgent, context.Background(), table)
+		}
+	}
+	get(testAgent.Impersonate("user", nil), context.Background(), "fresh")
+	session := NewSession(time.Minute)
+	session.wrote()
+	get(testAgent, WithSession(context.Background(), session), "fresh")
+
+	var expected = map[string]int{
+		"/fresh Bearer slave":                     1,
+		"/fresh Bearer user":                      1,
+		"/fresh Bearer master":                    1,
+		"/no_store Bearer slave":                  3,
+		"/etag Bearer slave":                      1,
+		"/etag Bearer slave conditional":          2,
+		"/last_modified Bearer slave":             1,
+		"/last_modified Bearer slave conditional": 2,
+	}
+	for key, count := range expected {
+		if server.count(key) != count {
+			t.Errorf("agent sent unexpected number of requests for %s:\nExpected: %d\nGot: %d", key, count, server.count(key))
+		}
+	}
+	if stats := testAgent.CacheStats(); stats != (CacheStats{Hits: 2, Revalidated: 4}) {
+		t.Errorf("CacheStats returned unexpected counts:\nExpected: %v\nGot: %v", CacheStats{Hits: 2, Revalidated: 4}, stats)
+	}
+}
+
+func TestResponseCacheInvalidation(t *testing.T) {
+	t.Parallel()
+
+	server := newCacheServer()
+	defer server.Close()
+	for _, path := range []string{"/fresh", "/other"} {
+		server.headers[path] = http.Header{"Cache-Control": {"max-age=60"}}
+	}
+
+	generator := func(claims interface{}, secret string) (string, error) { return claims.(*Claims).Role, nil }
+	testAgent, _ := NewAgent(&Config{
+		MasterBaseURL: server.URL,
+		MasterRole:    "master",
+		MasterSecret:  "master_secret",
+		SlaveBaseURL:  server.URL,
+		SlaveRole:     "slave",
+		SlaveSecret:   "slave_secret",
+		Timeout:       5,
+		ResponseCache: NewLRUCache(16, 0),
+	}, &http.Client{}, generator)
+	userAgent := testAgent.Impersonate("user", nil)
+
+	get := func(agent *Agent, table string) {
+		response, err := agent.Get(table, nil)
+		if err != nil {
+			t.Fatalf("Get returned unexpected error for %s: %v", table, err)
+		}
+		response.Body.Close()
+	}
+	for i := 0; i < 2; i++ {
+		get(testAgent, "fresh")
+		get(testAgent, "other")
+		get(userAgent, "fresh")
+	}
+	if _, err := userAgent.PatchJSON("fresh", nil, map[string]int{"a": 1}); err != nil {
+		t.Errorf("PatchJSON returned unexpected error: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		get(testAgent, "fresh")
+		get(testAgent, "other")
+		get(userAgent, "fresh")
+	}
+	if _, err := testAgent.RPCJSON("refresh", nil, nil); err != nil {
+		t.Errorf("RPCJSON returned unexpected error: %v", err)
+	}
+	get(testAgent, "other")
+
+	// the PATCH is counted along with the reads of the impersonating agent
+	var expected = map[string]int{
+		"/fresh Bearer slave": 2,
+		"/fresh Bearer user":  3,
+		"/other Bearer slave": 2,
+	}
+	for key, count := range expected {
+		if server.count(key) != count {
+			t.Errorf("agent sent unexpected number of requests for %s:\nExpected: %d\nGot: %d", key, count, server.count(key))
+		}
+	}
+}
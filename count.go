@@ -0,0 +1,137 @@
+package postgrest
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Count specifies how postgREST counts the total number of rows matched by a request
+type Count string
+
+// counting methods supported by postgREST
+const (
+	CountExact     Count = "exact"
+	CountPlanned   Count = "planned"
+	CountEstimated Count = "estimated"
+)
+
+// ContentRange is a parsed postgREST Content-Range header e.g: 0-24/3573458
+// End is less than Start when no rows were returned and Total is -1 when the total is unknown.
+type ContentRange struct {
+	Start int64
+	End   int64
+	Total int64
+}
+
+// Len returns the number of rows in the range
+func (r ContentRange) Len() int64 {
+	if r.End < r.Start {
+		return 0
+	}
+	return r.End - r.Start + 1
+}
+
+// ParseContentRange parses a postgREST Content-Range header e.g: "0-24/3573458", "0-24/*" or "*/0"
+func ParseContentRange(header string) (ContentRange, error) {
+	contentRange := ContentRange{End: -1, Total: -1}
+	header = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(header), "items"))
+	parts := strings.Split(header, "/")
+	if len(parts) != 2 {
+		return contentRange, errInvalidContentRange
+	}
+
+	var err error
+	if parts[1] != "*" {
+		if contentRange.Total, err = strconv.ParseInt(parts[1], 10, 64); err != nil {
+			return contentRange, errInvalidContentRange
+		}
+	}
+	if parts[0] == "*" {
+		return contentRange, nil
+	}
+	bounds := strings.Split(parts[0], "-")
+	if len(bounds) != 2 {
+		return contentRange, errInvalidContentRange
+	}
+	if contentRange.Start, err = strconv.ParseInt(bounds[0], 10, 64); err != nil {
+		return contentRange, errInvalidContentRange
+	}
+	if contentRange.End, err = strconv.ParseInt(bounds[1], 10, 64); err != nil {
+		return contentRange, errInvalidContentRange
+	}
+	return contentRange, nil
+}
+
+// Result contains the decoded rows of a postgREST response and the range of rows returned
+type Result struct {
+	StatusCode int
+	Rows       interface{}
+	Range      ContentRange
+}
+
+// newResult unmarshals the response into target and parses its Content-Range header
+func newResult(response *http.Response, target interface{}) (*Result, error) {
+	status, err := unmarshalResponse(response, target)
+	result := &Result{StatusCode: status, Rows: target, Range: ContentRange{End: -1, Total: -1}}
+	if err != nil {
+		return result, err
+	}
+	if header := response.Header.Get("Content-Range"); header != "" {
+		result.Range, err = ParseContentRange(header)
+	}
+	return result, err
+}
+
+// countHeader returns the Prefer header requesting the given count
+func countHeader(count Count) http.Header {
+	if count == "" {
+		return nil
+	}
+	return preferHeader("count=" + string(count))
+}
+
+// GetResult makes an HTTP GET request to the postgREST slave service and unmarshals the rows into target.
+// If count is not empty the total number of rows matching the query is returned in Result.Range.Total
+// Returns error if the response status code is not inclusively between 200 and 299
+func (agent *Agent) GetResult(table string, query *url.Values, count Count, target interface{}) (*Result, error) {
+	return agent.GetResultContext(context.Background(), table, query, count, target)
+}
+
+// GetResultContext is GetResult with the given context attached to the request
+func (agent *Agent) GetResultContext(ctx context.Context, table string, query *url.Values, count Count, target interface{}) (*Result, error) {
+	response, err := agent.send(ctx, http.MethodGet, table, query, countHeader(count), nil)
+	if err != nil {
+		return nil, err
+	}
+	return newResult(response, target)
+}
+
+// Count requests the total number of rows matching the query to be counted with the given method,
+// replacing the method of a previous call. An empty count requests no count.
+func (q *Query) Count(count Count) *Query {
+	q.count = count
+	return q
+}
+
+// requestHeader returns the header of the requests made by the query, preferring the count requested with Count
+func (q *Query) requestHeader() http.Header {
+	if q.count == "" {
+		return q.header
+	}
+	header := cloneHeader(q.header)
+	header.Add("Prefer", "count="+string(q.count))
+	return header
+}
+
+// GetResult makes an HTTP GET request for the query and unmarshals the rows into target.
+// The range of rows returned and the total requested with Count are returned in Result.Range
+func (q *Query) GetResult(target interface{}) (*Result, error) {
+	response, err := q.get(target)
+	if err != nil {
+		return nil, err
+	}
+	return newResult(response, target)
+}
@@ -0,0 +1,106 @@
+package postgrest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseContentRange(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		header   string
+		expected ContentRange
+		length   int64
+	}{
+		{"0-24/3573458", ContentRange{0, 24, 3573458}, 25},
+		{"10-19/*", ContentRange{10, 19, -1}, 10},
+		{"*/0", ContentRange{0, -1, 0}, 0},
+		{"*/*", ContentRange{0, -1, -1}, 0},
+		{"items 0-0/1", ContentRange{0, 0, 1}, 1},
+	}
+	for _, test := range tests {
+		contentRange, err := ParseContentRange(test.header)
+		if err != nil {
+			t.Errorf("ParseContentRange returned unexpected error: %v", err)
+		}
+		if contentRange != test.expected {
+			t.Errorf("ParseContentRange returned unexpected range:\nExpected: %v\nGot: %v", test.expected, contentRange)
+		}
+		if contentRange.Len() != test.length {
+			t.Errorf("Len returned unexpected length:\nExpected: %d\nGot: %d", test.length, contentRange.Len())
+		}
+	}
+
+	for _, header := range []string{"", "0-24", "a-24/1", "0-b/1", "0-24/c", "0/1"} {
+		if _, err := ParseContentRange(header); err == nil || err.Error() != errInvalidContentRange.Error() {
+			t.Errorf("ParseContentRange returned unexpected error:\nExpected: %v\nGot: %v", errInvalidContentRange, err)
+		}
+	}
+}
+
+func TestGetResult(t *testing.T) {
+	t.Parallel()
+
+	countServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch strings.Join(r.Header["Prefer"], ", ") {
+		case "count=exact":
+			w.Header().Set("Content-Range", "0-1/42")
+		case "count=planned":
+			w.Header().Set("Content-Range", "bad")
+		case "":
+			w.Header().Set("Content-Range", "0-1/*")
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `[{"id":1},{"id":2}]`)
+	}))
+	defer countServer.Close()
+	testAgent := newTestAgent(countServer.URL)
+
+	rows := []map[string]int{}
+	result, err := testAgent.From("test_table").Count(CountExact).GetResult(&rows)
+	if err != nil {
+		t.Errorf("GetResult returned unexpected error: %v", err)
+	}
+	expected := &Result{StatusCode: http.StatusOK, Rows: &rows, Range: ContentRange{0, 1, 42}}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("GetResult returned unexpected result:\nExpected: %v\nGot: %v", expected, result)
+	}
+	if len(rows) != 2 {
+		t.Errorf("GetResult returned unexpected rows:\nExpected: %d\nGot: %d", 2, len(rows))
+	}
+
+	query := testAgent.From("test_table").Count(CountExact)
+	for i := 0; i < 2; i++ {
+		if result, err = query.GetResult(&rows); err != nil || result.Range != (ContentRange{0, 1, 42}) {
+			t.Errorf("GetResult returned unexpected result:\nExpected: %v\nGot: %v %v", ContentRange{0, 1, 42}, result.Range, err)
+		}
+	}
+	if _, err = query.Count(CountPlanned).GetResult(&rows); err == nil || err.Error() != errInvalidContentRange.Error() {
+		t.Errorf("GetResult returned unexpected error:\nExpected: %v\nGot: %v", errInvalidContentRange, err)
+	}
+	if result, err = query.Count("").GetResult(&rows); err != nil || result.Range != (ContentRange{0, 1, -1}) {
+		t.Errorf("GetResult returned unexpected result:\nExpected: %v\nGot: %v %v", ContentRange{0, 1, -1}, result.Range, err)
+	}
+
+	result, err = testAgent.GetResult("test_table", nil, "", &rows)
+	if err != nil || result.Range != (ContentRange{0, 1, -1}) {
+		t.Errorf("GetResult returned unexpected result:\nExpected: %v\nGot: %v %v", ContentRange{0, 1, -1}, result.Range, err)
+	}
+
+	_, err = testAgent.GetResult("test_table", nil, CountPlanned, &rows)
+	if err == nil || err.Error() != errInvalidContentRange.Error() {
+		t.Errorf("GetResult returned unexpected error:\nExpected: %v\nGot: %v", errInvalidContentRange, err)
+	}
+
+	result, err = testAgent.GetResult("test_table", nil, CountEstimated, &rows)
+	if err == nil || result.StatusCode != http.StatusBadRequest {
+		t.Errorf("GetResult returned unexpected status code:\nExpected: %d\nGot: %d %v", http.StatusBadRequest, result.StatusCode, err)
+	}
+}
@@ -0,0 +1,139 @@
+package postgrest
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Embed describes a related resource embedded in the select list of a Query
+// e.g: NewEmbed("orders", "id", "total").Embed(NewEmbed("items")) produces orders(id,total,items(*))
+type Embed struct {
+	resource string
+	alias    string
+	hint     string
+	inner    bool
+	columns  []string
+	embeds   []*Embed
+	filters  []Filter
+	order    []string
+	limit    int
+	offset   int
+}
+
+// NewEmbed returns a new Embed for the given resource and columns. All columns are selected if none are given.
+func NewEmbed(resource string, columns ...string) *Embed {
+	return &Embed{resource: resource, columns: columns, limit: -1, offset: -1}
+}
+
+// As renames the embedded resource in the response
+func (e *Embed) As(alias string) *Embed {
+	e.alias = alias
+	return e
+}
+
+// Hint disambiguates the relationship used for embedding by foreign key or column name e.g: orders!fk_name
+func (e *Embed) Hint(hint string) *Embed {
+	e.hint = hint
+	return e
+}
+
+// Inner only returns parent rows that have at least one matching embedded row (!inner)
+func (e *Embed) Inner() *Embed {
+	e.inner = true
+	return e
+}
+
+// Select adds the given columns to the select list of the embedded resource
+func (e *Embed) Select(columns ...string) *Embed {
+	e.columns = append(e.columns, columns...)
+	return e
+}
+
+// Embed nests the given resources inside the embedded resource
+func (e *Embed) Embed(embeds ...*Embed) *Embed {
+	e.embeds = append(e.embeds, embeds...)
+	return e
+}
+
+// Where filters the rows of the embedded resource
+func (e *Embed) Where(filters ...Filter) *Embed {
+	e.filters = append(e.filters, filters...)
+	return e
+}
+
+// Order orders the rows of the embedded resource
+func (e *Embed) Order(column string, direction Direction) *Embed {
+	e.order = append(e.order, orderItem(column, direction))
+	return e
+}
+
+// Limit limits the number of rows of the embedded resource
+func (e *Embed) Limit(limit int) *Embed {
+	e.limit = limit
+	return e
+}
+
+// Offset skips the given number of rows of the embedded resource
+func (e *Embed) Offset(offset int) *Embed {
+	e.offset = offset
+	return e
+}
+
+// path returns the name used to scope query parameters to the embedded resource
+func (e *Embed) path() string {
+	if e.alias != "" {
+		return e.alias
+	}
+	return e.resource
+}
+
+// selectItem renders the embedded resource as a select list item e.g: alias:orders!fk_name!inner(id,total)
+func (e *Embed) selectItem() string {
+	item := e.resource
+	if e.alias != "" {
+		item = e.alias + ":" + item
+	}
+	if e.hint != "" {
+		item += "!" + e.hint
+	}
+	if e.inner {
+		item += "!inner"
+	}
+	return item + "(" + selectList(e.columns, e.embeds) + ")"
+}
+
+// addParams adds the filter, order and pagination parameters scoped to the embedded resource and its children
+func (e *Embed) addParams(values *url.Values, parent string) {
+	path := e.path()
+	if parent != "" {
+		path = parent + "." + path
+	}
+	for _, filter := range e.filters {
+		values.Add(path+"."+filter.key(), filter.value())
+	}
+	if len(e.order) > 0 {
+		values.Set(path+".order", strings.Join(e.order, ","))
+	}
+	if e.limit >= 0 {
+		values.Set(path+".limit", strconv.Itoa(e.limit))
+	}
+	if e.offset >= 0 {
+		values.Set(path+".offset", strconv.Itoa(e.offset))
+	}
+	for _, embed := range e.embeds {
+		embed.addParams(values, path)
+	}
+}
+
+// selectList renders columns and embedded resources as a postgREST select list
+func selectList(columns []string, embeds []*Embed) string {
+	items := append([]string{}, columns...)
+	if len(items) == 0 {
+		items = []string{"*"}
+	}
+	for _, embed := range embeds {
+		items = append(items, embed.selectItem())
+	}
+	return strings.Join(items, ",")
+}
@@ -0,0 +1,85 @@
+package postgrest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestEmbedValues(t *testing.T) {
+	t.Parallel()
+
+	testAgent := newTestAgent(server.URL)
+	values := testAgent.From("users").
+		Select("id", "email").
+		Embed(
+			NewEmbed("orders", "id", "total").
+				Hint("orders_user_id_fkey").
+				Inner().
+				Where(Gt("total", 100), Or(Eq("status", "open"), Eq("status", "paid"))).
+				Order("created_at", Desc).
+				Limit(5).
+				Embed(NewEmbed("items").Limit(3)),
+			NewEmbed("addresses", "city").As("home").Where(Eq("kind", "home")).Offset(1),
+		).
+		Values()
+
+	expected := &url.Values{
+		"select":             {"id,email,orders!orders_user_id_fkey!inner(id,total,items(*)),home:addresses(city)"},
+		"orders.total":       {"gt.100"},
+		"orders.or":          {"(status.eq.open,status.eq.paid)"},
+		"orders.order":       {"created_at.desc"},
+		"orders.limit":       {"5"},
+		"orders.items.limit": {"3"},
+		"home.kind":          {"eq.home"},
+		"home.offset":        {"1"},
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Values returned unexpected results:\nExpected: %v\nGot: %v", expected, values)
+	}
+
+	values = testAgent.From("users").Embed(NewEmbed("orders")).Values()
+	if selectStr := values.Get("select"); selectStr != "*,orders(*)" {
+		t.Errorf("Values returned unexpected select:\nExpected: %s\nGot: %s", "*,orders(*)", selectStr)
+	}
+}
+
+func TestEmbedGetJSON(t *testing.T) {
+	t.Parallel()
+
+	embedServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("select") != "id,orders(id,items(*))" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `[{"id":1,"orders":[{"id":2,"items":[{"id":3,"name":"widget"}]}]}]`)
+	}))
+	defer embedServer.Close()
+
+	type item struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	type order struct {
+		ID    int    `json:"id"`
+		Items []item `json:"items"`
+	}
+	type user struct {
+		ID     int     `json:"id"`
+		Orders []order `json:"orders"`
+	}
+
+	users := []user{}
+	testAgent := newTestAgent(embedServer.URL)
+	_, err := testAgent.From("users").Select("id").Embed(NewEmbed("orders", "id").Embed(NewEmbed("items"))).GetJSON(&users)
+	if err != nil {
+		t.Errorf("GetJSON returned unexpected error: %v", err)
+	}
+	expected := []user{{ID: 1, Orders: []order{{ID: 2, Items: []item{{ID: 3, Name: "widget"}}}}}}
+	if !reflect.DeepEqual(users, expected) {
+		t.Errorf("GetJSON returned unexpected results:\nExpected: %v\nGot: %v", expected, users)
+	}
+}
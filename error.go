@@ -0,0 +1,131 @@
+package postgrest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// maxErrorBodySize limits the size of the error response body read into an Error
+const maxErrorBodySize = 1 << 20
+
+// SQLSTATE codes reported by postgREST
+const (
+	codeForeignKeyViolation = "23503"
+	codeUniqueViolation     = "23505"
+	codeInsufficientPrivs   = "42501"
+	codeSingularResult      = "PGRST116"
+)
+
+// Error is returned when a postgREST response status code is not inclusively between 200 and 299.
+// Code contains the SQLSTATE of database errors or the postgREST error code.
+type Error struct {
+	StatusCode int    `json:"-"`
+	Status     string `json:"-"`
+	Method     string `json:"-"`
+	URL        string `json:"-"`
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+	Details    string `json:"details"`
+	Hint       string `json:"hint"`
+}
+
+// Error returns the error message
+func (e *Error) Error() string {
+	message := fmt.Sprintf("postgrest error (%s %s): %s", e.Method, e.URL, e.Status)
+	if e.Message != "" {
+		message += ": " + e.Message
+	}
+	if e.Code != "" {
+		message += " (" + e.Code + ")"
+	}
+	return message
+}
+
+// newError reads the postgREST error details from the body of response
+func newError(response *http.Response) *Error {
+	e := &Error{StatusCode: response.StatusCode, Status: response.Status}
+	if response.Request != nil {
+		e.Method = response.Request.Method
+		e.URL = response.Request.URL.String()
+	}
+	// bodies that do not contain postgREST error details are ignored
+	// and the body is restored so that it can still be read by the caller
+	if body, err := ioutil.ReadAll(io.LimitReader(response.Body, maxErrorBodySize)); err == nil {
+		_ = json.Unmarshal(body, e)
+		response.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	return e
+}
+
+// asError returns the first *Error in the chain of wrapped errors
+func asError(err error) (*Error, bool) {
+	for err != nil {
+		if e, ok := err.(*Error); ok {
+			return e, true
+		}
+		wrapper, ok := err.(interface {
+			Unwrap() error
+		})
+		if !ok {
+			return nil, false
+		}
+		err = wrapper.Unwrap()
+	}
+	return nil, false
+}
+
+// IsUniqueViolation returns true if err is a postgREST unique constraint violation
+func IsUniqueViolation(err error) bool {
+	e, ok := asError(err)
+	return ok && e.Code == codeUniqueViolation
+}
+
+// IsForeignKeyViolation returns true if err is a postgREST foreign key constraint violation
+func IsForeignKeyViolation(err error) bool {
+	e, ok := asError(err)
+	return ok && e.Code == codeForeignKeyViolation
+}
+
+// IsNotFound returns true if err is a postgREST not found error or
+// a single object was requested and no rows were returned
+func IsNotFound(err error) bool {
+	e, ok := asError(err)
+	if !ok {
+		return false
+	}
+	if e.StatusCode == http.StatusNotFound {
+		return true
+	}
+	return (e.Code == codeSingularResult || e.StatusCode == http.StatusNotAcceptable) && strings.Contains(e.Details, " 0 rows")
+}
+
+// IsPermissionDenied returns true if err is a postgREST authentication or authorization error
+func IsPermissionDenied(err error) bool {
+	e, ok := asError(err)
+	if !ok {
+		return false
+	}
+	return e.Code == codeInsufficientPrivs || e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+}
+
+// IsCircuitOpen returns true if the request was short-circuited by an open circuit breaker
+func IsCircuitOpen(err error) bool {
+	for err != nil {
+		if err == ErrCircuitOpen {
+			return true
+		}
+		wrapper, ok := err.(interface {
+			Unwrap() error
+		})
+		if !ok {
+			return false
+		}
+		err = wrapper.Unwrap()
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+package postgrest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+type wrappedError struct {
+	err error
+}
+
+func (w wrappedError) Error() string { return "wrapped: " + w.err.Error() }
+func (w wrappedError) Unwrap() error { return w.err }
+
+func TestError(t *testing.T) {
+	t.Parallel()
+
+	errorServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+		fmt.Fprint(w, `{"code":"23505","message":"duplicate key value violates unique constraint \"users_email_key\"",`+
+			`"details":"Key (email)=(a@b.c) already exists.","hint":null}`)
+	}))
+	defer errorServer.Close()
+	testAgent := newTestAgent(errorServer.URL)
+
+	status, err := testAgent.PostJSON("users", testObject, nil)
+	if status != http.StatusConflict {
+		t.Errorf("PostJSON returned unexpected status code:\nExpected: %d\nGot: %d", http.StatusConflict, status)
+	}
+	e, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("PostJSON returned unexpected error type:\nExpected: %T\nGot: %T", e, err)
+	}
+	expected := &Error{
+		StatusCode: http.StatusConflict,
+		Status:     "409 Conflict",
+		Method:     http.MethodPost,
+		URL:        errorServer.URL + "/users",
+		Code:       "23505",
+		Message:    `duplicate key value violates unique constraint "users_email_key"`,
+		Details:    "Key (email)=(a@b.c) already exists.",
+	}
+	if !reflect.DeepEqual(e, expected) {
+		t.Errorf("PostJSON returned unexpected error:\nExpected: %#v\nGot: %#v", expected, e)
+	}
+	expectedMessage := fmt.Sprintf("postgrest error (POST %s/users): 409 Conflict: %s (23505)", errorServer.URL, expected.Message)
+	if err.Error() != expectedMessage {
+		t.Errorf("Error returned unexpected message:\nExpected: %s\nGot: %s", expectedMessage, err.Error())
+	}
+	if !IsUniqueViolation(wrappedError{err}) {
+		t.Error("IsUniqueViolation did not detect wrapped unique violation")
+	}
+}
+
+func TestErrorHelpers(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		err                 error
+		uniqueViolation     bool
+		foreignKeyViolation bool
+		notFound            bool
+		permissionDenied    bool
+	}{
+		{&Error{StatusCode: http.StatusConflict, Code: "23505"}, true, false, false, false},
+		{&Error{StatusCode: http.StatusConflict, Code: "23503"}, false, true, false, false},
+		{&Error{StatusCode: http.StatusNotFound}, false, false, true, false},
+		{&Error{StatusCode: http.StatusNotAcceptable, Code: "PGRST116", Details: "The result contains 0 rows"}, false, false, true, false},
+		{&Error{StatusCode: http.StatusNotAcceptable, Code: "PGRST116", Details: "The result contains 2 rows"}, false, false, false, false},
+		{&Error{StatusCode: http.StatusForbidden, Code: "42501"}, false, false, false, true},
+		{&Error{StatusCode: http.StatusUnauthorized}, false, false, false, true},
+		{wrappedError{&Error{StatusCode: http.StatusNotFound}}, false, false, true, false},
+		{errors.New("23505"), false, false, false, false},
+		{nil, false, false, false, false},
+	}
+	for _, test := range tests {
+		if IsUniqueViolation(test.err) != test.uniqueViolation {
+			t.Errorf("IsUniqueViolation returned unexpected result for %v:\nExpected: %t", test.err, test.uniqueViolation)
+		}
+		if IsForeignKeyViolation(test.err) != test.foreignKeyViolation {
+			t.Errorf("IsForeignKeyViolation returned unexpected result for %v:\nExpected: %t", test.err, test.foreignKeyViolation)
+		}
+		if IsNotFound(test.err) != test.notFound {
+			t.Errorf("IsNotFound returned unexpected result for %v:\nExpected: %t", test.err, test.notFound)
+		}
+		if IsPermissionDenied(test.err) != test.permissionDenied {
+			t.Errorf("IsPermissionDenied returned unexpected result for %v:\nExpected: %t", test.err, test.permissionDenied)
+		}
+	}
+}
+
+func TestIsCircuitOpen(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		err      error
+		expected bool
+	}{
+		{nil, false},
+		{ErrCircuitOpen, true},
+		{wrappedError{ErrCircuitOpen}, true},
+		{errMissingURLPath, false},
+		{&Error{StatusCode: http.StatusServiceUnavailable}, false},
+	}
+	for _, test := range tests {
+		if IsCircuitOpen(test.err) != test.expected {
+			t.Errorf("IsCircuitOpen returned unexpected result for %v:\nExpected: %v\nGot: %v", test.err, test.expected, !test.expected)
+		}
+	}
+}
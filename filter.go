@@ -0,0 +1,223 @@
+package postgrest
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Filter is a node of a postgREST filter expression.
+// Filters are created with the condition functions (Eq, Gt, In, ...) and composed with Or, And and Not.
+type Filter interface {
+	// key returns the query parameter key of the filter e.g: "age" or "or"
+	key() string
+	// value returns the query parameter value of the filter e.g: "gt.18" or "(a.eq.1,b.eq.2)"
+	value() string
+	// logic renders the filter as a member of a logical group e.g: "age.gt.18" or "or(a.eq.1,b.eq.2)"
+	logic() string
+}
+
+// condition is a single `column=operator.value` filter
+type condition struct {
+	column   string
+	operator string
+	operand  string
+	// literal is true when operand is already formatted and must not be quoted inside logical groups
+	literal bool
+	negated bool
+}
+
+func (c condition) key() string {
+	return c.column
+}
+
+func (c condition) value() string {
+	return c.prefix() + c.operator + "." + c.operand
+}
+
+func (c condition) logic() string {
+	operand := c.operand
+	if !c.literal {
+		operand = quoteValue(operand)
+	}
+	return c.column + "." + c.prefix() + c.operator + "." + operand
+}
+
+func (c condition) prefix() string {
+	if c.negated {
+		return "not."
+	}
+	return ""
+}
+
+// group is a logical `or` / `and` combination of filters
+type group struct {
+	operator string
+	filters  []Filter
+	negated  bool
+}
+
+func (g group) key() string {
+	if g.negated {
+		return "not." + g.operator
+	}
+	return g.operator
+}
+
+func (g group) value() string {
+	members := make([]string, len(g.filters))
+	for i, filter := range g.filters {
+		members[i] = filter.logic()
+	}
+	return "(" + strings.Join(members, ",") + ")"
+}
+
+func (g group) logic() string {
+	return g.key() + g.value()
+}
+
+// scopedFilter applies a filter to an embedded resource e.g: orders.or=(...)
+type scopedFilter struct {
+	resource string
+	filter   Filter
+}
+
+func (s scopedFilter) key() string {
+	return s.resource + "." + s.filter.key()
+}
+
+func (s scopedFilter) value() string {
+	return s.filter.value()
+}
+
+func (s scopedFilter) logic() string {
+	return s.filter.logic()
+}
+
+// Or returns a filter matching rows that satisfy any of the given filters
+func Or(filters ...Filter) Filter {
+	return group{operator: "or", filters: filters}
+}
+
+// And returns a filter matching rows that satisfy all of the given filters
+func And(filters ...Filter) Filter {
+	return group{operator: "and", filters: filters}
+}
+
+// Not returns the negation of the given filter
+func Not(filter Filter) Filter {
+	switch f := filter.(type) {
+	case condition:
+		f.negated = !f.negated
+		return f
+	case group:
+		f.negated = !f.negated
+		return f
+	case scopedFilter:
+		return scopedFilter{f.resource, Not(f.filter)}
+	}
+	return filter
+}
+
+// Raw returns a `column=operator.value` filter. The value is used verbatim and is not quoted or escaped.
+func Raw(column, operator, value string) Filter {
+	return condition{column: column, operator: operator, operand: value, literal: true}
+}
+
+// Eq returns a filter matching rows where column is equal to value, or where column is null if value is nil
+func Eq(column string, value interface{}) Filter {
+	if value == nil {
+		return Is(column, nil)
+	}
+	return condition{column: column, operator: "eq", operand: formatValue(value)}
+}
+
+// Neq returns a filter matching rows where column is not equal to value, or where column is not null if value is nil
+func Neq(column string, value interface{}) Filter {
+	if value == nil {
+		return Not(Is(column, nil))
+	}
+	return condition{column: column, operator: "neq", operand: formatValue(value)}
+}
+
+// Gt returns a filter matching rows where column is greater than value
+func Gt(column string, value interface{}) Filter {
+	return condition{column: column, operator: "gt", operand: formatValue(value)}
+}
+
+// Gte returns a filter matching rows where column is greater than or equal to value
+func Gte(column string, value interface{}) Filter {
+	return condition{column: column, operator: "gte", operand: formatValue(value)}
+}
+
+// Lt returns a filter matching rows where column is less than value
+func Lt(column string, value interface{}) Filter {
+	return condition{column: column, operator: "lt", operand: formatValue(value)}
+}
+
+// Lte returns a filter matching rows where column is less than or equal to value
+func Lte(column string, value interface{}) Filter {
+	return condition{column: column, operator: "lte", operand: formatValue(value)}
+}
+
+// Like returns a filter matching rows where column matches the LIKE pattern
+func Like(column, pattern string) Filter {
+	return condition{column: column, operator: "like", operand: pattern}
+}
+
+// Ilike returns a filter matching rows where column matches the case insensitive ILIKE pattern
+func Ilike(column, pattern string) Filter {
+	return condition{column: column, operator: "ilike", operand: pattern}
+}
+
+// Is returns a filter matching rows where column IS value. value must be nil, a bool or "unknown"
+func Is(column string, value interface{}) Filter {
+	return condition{column: column, operator: "is", operand: formatValue(value)}
+}
+
+// In returns a filter matching rows where column is one of the given values
+func In(column string, values ...interface{}) Filter {
+	return condition{column: column, operator: "in", operand: formatList(values), literal: true}
+}
+
+// Contains returns a filter matching rows where column contains value (cs)
+func Contains(column string, value interface{}) Filter {
+	return condition{column: column, operator: "cs", operand: formatCollection(value), literal: true}
+}
+
+// ContainedBy returns a filter matching rows where column is contained by value (cd)
+func ContainedBy(column string, value interface{}) Filter {
+	return condition{column: column, operator: "cd", operand: formatCollection(value), literal: true}
+}
+
+// Overlaps returns a filter matching rows where column has elements in common with value (ov)
+func Overlaps(column string, value interface{}) Filter {
+	return condition{column: column, operator: "ov", operand: formatCollection(value), literal: true}
+}
+
+// Fts returns a filter matching rows where the tsvector column matches the to_tsquery of query
+func Fts(column, query, config string) Filter {
+	return condition{column: column, operator: ftsOperator("fts", config), operand: query}
+}
+
+// Plfts returns a filter matching rows where the tsvector column matches the plainto_tsquery of query
+func Plfts(column, query, config string) Filter {
+	return condition{column: column, operator: ftsOperator("plfts", config), operand: query}
+}
+
+// Phfts returns a filter matching rows where the tsvector column matches the phraseto_tsquery of query
+func Phfts(column, query, config string) Filter {
+	return condition{column: column, operator: ftsOperator("phfts", config), operand: query}
+}
+
+// Wfts returns a filter matching rows where the tsvector column matches the websearch_to_tsquery of query
+func Wfts(column, query, config string) Filter {
+	return condition{column: column, operator: ftsOperator("wfts", config), operand: query}
+}
+
+// ftsOperator returns a full text search operator with an optional text search configuration
+func ftsOperator(operator, config string) string {
+	if config == "" {
+		return operator
+	}
+	return fmt.Sprintf("%s(%s)", operator, config)
+}
@@ -0,0 +1,72 @@
+package postgrest
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestFilterLogic(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		filter        Filter
+		expectedKey   string
+		expectedValue string
+	}{
+		{Eq("a", 1), "a", "eq.1"},
+		{Not(Eq("a", 1)), "a", "not.eq.1"},
+		{Not(Not(Eq("a", 1))), "a", "eq.1"},
+		{Eq("a", nil), "a", "is.null"},
+		{Neq("a", nil), "a", "not.is.null"},
+		{Not(Neq("a", nil)), "a", "is.null"},
+		{Or(Eq("a", nil), Neq("b", nil)), "or", "(a.is.null,b.not.is.null)"},
+		{Or(Lt("age", 18), Gt("age", 21)), "or", "(age.lt.18,age.gt.21)"},
+		{And(Gte("age", 18), Not(Is("student", true))), "and", "(age.gte.18,student.not.is.true)"},
+		{
+			Or(Eq("name", "Smith, John"), Eq("name", `say "hi" (twice)`), Eq("email", "a@b.com")),
+			"or",
+			`(name.eq."Smith, John",name.eq."say \"hi\" (twice)",email.eq."a@b.com")`,
+		},
+		{
+			Or(Eq("grade", 4), And(Gte("age", 18), Lte("age", 21)), Not(And(Eq("a", 1), Eq("b", 2)))),
+			"or",
+			"(grade.eq.4,and(age.gte.18,age.lte.21),not.and(a.eq.1,b.eq.2))",
+		},
+		{Not(Or(In("id", 1, "x,y"), Contains("tags", []string{"a", "b"}))), "not.or", `(id.in.(1,"x,y"),tags.cs.{a,b})`},
+		{Or(Fts("body", "cat & dog", "english"), Raw("a", "eq", "(raw)")), "or", "(body.fts(english).cat & dog,a.eq.(raw))"},
+	}
+	for _, test := range tests {
+		if key := test.filter.key(); key != test.expectedKey {
+			t.Errorf("Filter returned unexpected key:\nExpected: %s\nGot: %s", test.expectedKey, key)
+		}
+		if value := test.filter.value(); value != test.expectedValue {
+			t.Errorf("Filter returned unexpected value:\nExpected: %s\nGot: %s", test.expectedValue, value)
+		}
+	}
+}
+
+func TestQueryLogic(t *testing.T) {
+	t.Parallel()
+
+	testAgent := newTestAgent(server.URL)
+	values := testAgent.From("parents").
+		Or(Eq("a", 1), Eq("b", 2)).
+		Not(Eq("c", 3)).
+		Where(Not(And(Eq("d", 4), Eq("e", 5)))).
+		WhereEmbedded("children", Or(Eq("f", 6), Eq("g", 7)), Not(Eq("h", 8))).
+		WhereEmbedded("children.toys", Eq("i", 9)).
+		Values()
+
+	expected := &url.Values{
+		"or":              {"(a.eq.1,b.eq.2)"},
+		"c":               {"not.eq.3"},
+		"not.and":         {"(d.eq.4,e.eq.5)"},
+		"children.or":     {"(f.eq.6,g.eq.7)"},
+		"children.h":      {"not.eq.8"},
+		"children.toys.i": {"eq.9"},
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Values returned unexpected results:\nExpected: %v\nGot: %v", expected, values)
+	}
+}
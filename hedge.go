@@ -0,0 +1,98 @@
+package postgrest
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// hedgeResult is the outcome of the request of index i of a hedged read
+type hedgeResult struct {
+	i        int
+	response *http.Response
+	err      error
+}
+
+// hedge sends the read to endpoint and, when it has not answered within the hedge delay,
+// to another healthy replica of the pool. The first successful response is returned and the other
+// request is canceled. The hedged request counts as an attempt of the retry policy and is only sent
+// when the replica limits allow it without waiting.
+func (agent *Agent) hedge(ctx context.Context, pool *replicaPool, endpoint *Endpoint, c *call) (*http.Response, error) {
+	if agent.hedgeDelay <= 0 || len(pool.endpoints) < 2 {
+		return agent.sendTo(ctx, endpoint, c)
+	}
+
+	results := make(chan hedgeResult, 2)
+	var cancels []context.CancelFunc
+	start := func(send func(ctx context.Context) (*http.Response, error)) {
+		hedgeCtx, cancel := context.WithCancel(ctx)
+		i := len(cancels)
+		cancels = append(cancels, cancel)
+		go func() {
+			response, err := send(hedgeCtx)
+			results <- hedgeResult{i, response, err}
+		}()
+	}
+	start(func(ctx context.Context) (*http.Response, error) {
+		return agent.sendTo(ctx, endpoint, c)
+	})
+	timer := time.NewTimer(agent.hedgeDelay)
+	defer timer.Stop()
+
+	pending := 1
+	var failed *hedgeResult
+	for {
+		select {
+		case result := <-results:
+			pending--
+			if isUnavailable(result.response, result.err) && pending > 0 {
+				failed = &result
+				continue
+			}
+			for i, cancel := range cancels {
+				if i != result.i {
+					cancel()
+				}
+			}
+			if failed != nil && failed.response != nil {
+				failed.response.Body.Close()
+			}
+			go drainHedge(results, pending)
+			if result.err != nil {
+				cancels[result.i]()
+				return nil, result.err
+			}
+			result.response.Body = &releasingBody{ReadCloser: result.response.Body, release: cancels[result.i]}
+			return result.response, nil
+		case <-timer.C:
+			other, ok := pool.pickExcept(endpoint)
+			if !ok || !c.canAttempt() {
+				continue
+			}
+			limit, ok := other.limiter.tryAcquire()
+			if !ok {
+				continue
+			}
+			atomic.AddUint64(&pool.hedges, 1)
+			pending++
+			start(func(ctx context.Context) (*http.Response, error) {
+				request, err := agent.newCallRequest(ctx, other, c)
+				if err != nil {
+					limit()
+					return nil, err
+				}
+				return agent.sendAcquired(ctx, other, c, request, limit)
+			})
+		}
+	}
+}
+
+// drainHedge closes the responses of the pending requests of a hedged read
+func drainHedge(results <-chan hedgeResult, pending int) {
+	for ; pending > 0; pending-- {
+		if result := <-results; result.response != nil {
+			result.response.Body.Close()
+		}
+	}
+}
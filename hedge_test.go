@@ -0,0 +1,112 @@
+package postgrest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitUntil polls cond until it returns true, failing the test after 5 seconds
+func waitUntil(t *testing.T, message string, cond func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting until %s", message)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestHedge(t *testing.T) {
+	t.Parallel()
+
+	arrived, canceled, release := make(chan struct{}, 4), make(chan struct{}, 4), make(chan struct{})
+	slowArrived, once := make(chan struct{}), sync.Once{}
+	slowServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() { close(slowArrived) })
+		arrived <- struct{}{}
+		select {
+		case <-r.Context().Done():
+			canceled <- struct{}{}
+		case <-release:
+			w.Write([]byte(`["slow"]`))
+		}
+	}))
+	defer slowServer.Close()
+	// the fast server answers once the slow server is reached so that the slow request is canceled server side
+	fastServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-slowArrived
+		w.Write([]byte(`["fast"]`))
+	}))
+	defer fastServer.Close()
+
+	newHedgingAgent := func(policy *RetryPolicy, limits *Limits) *Agent {
+		testAgent, _ := NewAgent(&Config{
+			MasterBaseURL: fastServer.URL,
+			MasterRole:    "master",
+			MasterSecret:  "master_secret",
+			SlaveBaseURL:  slowServer.URL,
+			SlaveRole:     "slave",
+			SlaveSecret:   "slave_secret",
+			Timeout:       5,
+			Replicas:      []Replica{{BaseURL: fastServer.URL}},
+			Balancer:      balancerFunc(func(endpoints []*Endpoint) *Endpoint { return endpoints[0] }),
+			RetryPolicy:   policy,
+			SlaveLimits:   limits,
+			HedgeDelay:    10 * time.Millisecond,
+		}, &http.Client{}, nil)
+		return testAgent
+	}
+
+	testAgent := newHedgingAgent(nil, nil)
+	rows := []string{}
+	if _, err := testAgent.GetJSON("test_table", nil, &rows); err != nil {
+		t.Errorf("GetJSON returned unexpected error: %v", err)
+	}
+	if len(rows) != 1 || rows[0] != "fast" {
+		t.Errorf("GetJSON returned unexpected rows:\nExpected: %v\nGot: %v", []string{"fast"}, rows)
+	}
+	if stats := testAgent.ReadStats(); stats.Hedges != 1 {
+		t.Errorf("ReadStats returned unexpected hedges:\nExpected: %d\nGot: %d", 1, stats.Hedges)
+	}
+	<-arrived
+	select {
+	case <-canceled:
+	case <-time.After(5 * time.Second):
+		t.Errorf("hedged read did not cancel the slow request")
+	}
+	for _, endpoint := range testAgent.replicas.endpoints {
+		waitUntil(t, endpoint.BaseURL()+" has no outstanding requests", func() bool { return endpoint.Outstanding() == 0 })
+	}
+
+	budgetAgent := newHedgingAgent(&RetryPolicy{MaxAttempts: 1}, nil)
+	limitedAgent := newHedgingAgent(nil, &Limits{MaxInFlight: 1})
+	results := make(chan []string, 2)
+	for _, agent := range []*Agent{budgetAgent, limitedAgent} {
+		agent := agent
+		go func() {
+			rows := []string{}
+			if _, err := agent.GetJSON("test_table", nil, &rows); err != nil {
+				t.Errorf("GetJSON returned unexpected error: %v", err)
+			}
+			results <- rows
+		}()
+	}
+	<-arrived
+	<-arrived
+	// the slow requests are held well past the hedge delay, a late timer can only weaken the check
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	for i := 0; i < 2; i++ {
+		if rows := <-results; len(rows) != 1 || rows[0] != "slow" {
+			t.Errorf("GetJSON hedged a read beyond its budget: %v", rows)
+		}
+	}
+	for _, agent := range []*Agent{budgetAgent, limitedAgent} {
+		if hedges := agent.ReadStats().Hedges; hedges != 0 {
+			t.Errorf("ReadStats returned unexpected hedges:\nExpected: %d\nGot: %d", 0, hedges)
+		}
+	}
+}
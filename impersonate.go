@@ -0,0 +1,44 @@
+package postgrest
+
+import "encoding/json"
+
+// impersonation is the role and extra claims used by an impersonating agent
+type impersonation struct {
+	role   string
+	claims map[string]interface{}
+	key    string
+	err    error
+}
+
+// Impersonate returns a copy of the agent signing its requests with role and the given extra claims,
+// e.g: a user or tenant id used by row level security policies through request.jwt.claims.
+// The role replaces both MasterRole and SlaveRole; an empty role keeps them.
+// Extra claims named role, iss or exp are ignored. The returned agent shares the http client and token cache.
+func (agent *Agent) Impersonate(role string, claims map[string]interface{}) *Agent {
+	scope := &impersonation{role: role, claims: make(map[string]interface{}, len(claims))}
+	for name, value := range claims {
+		scope.claims[name] = value
+	}
+	key, err := json.Marshal(scope.claims)
+	scope.key, scope.err = string(key), err
+
+	scoped := *agent
+	scoped.scope = scope
+	return &scoped
+}
+
+// scopedRole returns the role and extra claims of the agent's tokens, role unless it impersonates another one,
+// along with the key identifying the extra claims
+func (agent *Agent) scopedRole(role string) (string, map[string]interface{}, string, error) {
+	scope := agent.scope
+	if scope == nil {
+		return role, nil, "", nil
+	}
+	if scope.err != nil {
+		return "", nil, "", scope.err
+	}
+	if scope.role != "" {
+		role = scope.role
+	}
+	return role, scope.claims, scope.key, nil
+}
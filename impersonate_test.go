@@ -0,0 +1,95 @@
+package postgrest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestClaimsMarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		claims   Claims
+		expected string
+	}{
+		{Claims{Role: "user", ExpiresAt: 10}, `{"role":"user","exp":10}`},
+		{
+			Claims{Role: "user", Issuer: "test", ExpiresAt: 10, Extra: map[string]interface{}{"user_id": 5, "role": "admin", "exp": 99}},
+			`{"exp":10,"iss":"test","role":"user","user_id":5}`,
+		},
+		{Claims{Extra: map[string]interface{}{"tenant": "acme", "iss": "other"}}, `{"tenant":"acme"}`},
+	}
+	for _, test := range tests {
+		b, err := json.Marshal(&test.claims)
+		if err != nil || string(b) != test.expected {
+			t.Errorf("MarshalJSON returned unexpected json:\nExpected: %s\nGot: %s %v", test.expected, b, err)
+		}
+	}
+}
+
+func TestImpersonate(t *testing.T) {
+	t.Parallel()
+
+	var tokens []string
+	impersonateServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokens = append(tokens, strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+		w.Write([]byte("[]"))
+	}))
+	defer impersonateServer.Close()
+
+	config := &Config{
+		MasterBaseURL: impersonateServer.URL,
+		MasterRole:    "master",
+		MasterSecret:  "master_secret",
+		SlaveBaseURL:  impersonateServer.URL,
+		SlaveRole:     "slave",
+		SlaveSecret:   "slave_secret",
+		Timeout:       60,
+	}
+	testAgent, err := NewAgent(config, &http.Client{}, nil)
+	if err != nil {
+		t.Fatalf("NewAgent returned unexpected error: %v", err)
+	}
+	userAgent := testAgent.Impersonate("web_user", map[string]interface{}{"user_id": "42", "tenant_id": 7})
+
+	userAgent.Get("test_table", nil)
+	userAgent.Post("test_table", strings.NewReader("{}"))
+	userAgent.Impersonate("", map[string]interface{}{"user_id": "43"}).Get("test_table", nil)
+	testAgent.Get("test_table", nil)
+
+	var expected = []struct {
+		claims map[string]interface{}
+		secret string
+	}{
+		{map[string]interface{}{"role": "web_user", "user_id": "42", "tenant_id": float64(7)}, "slave_secret"},
+		{map[string]interface{}{"role": "web_user", "user_id": "42", "tenant_id": float64(7)}, "master_secret"},
+		{map[string]interface{}{"role": "slave", "user_id": "43"}, "slave_secret"},
+		{map[string]interface{}{"role": "slave"}, "slave_secret"},
+	}
+	if len(tokens) != len(expected) {
+		t.Fatalf("agent made unexpected number of requests:\nExpected: %d\nGot: %d", len(expected), len(tokens))
+	}
+	for i, token := range tokens {
+		parts := strings.Split(token, ".")
+		payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+		claims := map[string]interface{}{}
+		json.Unmarshal(payload, &claims)
+		delete(claims, "exp")
+		if !reflect.DeepEqual(claims, expected[i].claims) {
+			t.Errorf("agent signed unexpected claims:\nExpected: %v\nGot: %v", expected[i].claims, claims)
+		}
+		signed, _ := HS256(json.RawMessage(payload), expected[i].secret)
+		if !strings.HasSuffix(signed, parts[2]) {
+			t.Errorf("agent signed claims %v with unexpected secret:\nExpected: %s", claims, expected[i].secret)
+		}
+	}
+
+	if _, err := testAgent.Impersonate("web_user", map[string]interface{}{"invalid": func() {}}).Get("test_table", nil); err == nil {
+		t.Error("Get returned no error for unencodable claims")
+	}
+}
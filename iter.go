@@ -0,0 +1,125 @@
+package postgrest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Iterator walks the rows matched by a Query one at a time, fetching them page by page
+// with the Range and Range-Unit headers until the Content-Range of the responses is exhausted
+// e.g:
+//
+//	it := agent.From("events").Order("id", Asc).Iter(ctx, 100)
+//	for it.Next() {
+//		var e event
+//		if err := it.Scan(&e); err != nil {
+//			return err
+//		}
+//	}
+//	return it.Err()
+type Iterator struct {
+	query     *Query
+	ctx       context.Context
+	pageSize  int64
+	start     int64
+	remaining int64
+	total     int64
+	rows      []json.RawMessage
+	current   json.RawMessage
+	done      bool
+	err       error
+}
+
+// Iter returns an Iterator over the rows matched by the query fetching pageSize rows per request.
+// The offset and limit of the query bound the rows that are iterated.
+func (q *Query) Iter(ctx context.Context, pageSize int) *Iterator {
+	it := &Iterator{query: q, ctx: ctx, pageSize: int64(pageSize), remaining: -1, total: -1, err: q.err}
+	if q.offset > 0 {
+		it.start = int64(q.offset)
+	}
+	if q.limit >= 0 {
+		it.remaining = int64(q.limit)
+	}
+	if pageSize <= 0 {
+		it.err = errInvalidPageSize
+	}
+	return it
+}
+
+// Next advances the iterator to the next row, fetching the next page if necessary.
+// It returns false when the rows are exhausted or an error occurred.
+func (it *Iterator) Next() bool {
+	for len(it.rows) == 0 {
+		if it.err != nil || it.done || it.remaining == 0 {
+			it.current = nil
+			return false
+		}
+		it.err = it.fetch()
+	}
+	it.current, it.rows = it.rows[0], it.rows[1:]
+	return true
+}
+
+// Scan unmarshals the current row into dest
+func (it *Iterator) Scan(dest interface{}) error {
+	if it.current == nil {
+		return errNoCurrentRow
+	}
+	return json.Unmarshal(it.current, dest)
+}
+
+// Err returns the error that stopped the iteration, if any
+func (it *Iterator) Err() error {
+	return it.err
+}
+
+// Total returns the total number of rows reported by postgREST or -1 if it is unknown.
+// The total is only reported when the query requests a Count.
+func (it *Iterator) Total() int64 {
+	return it.total
+}
+
+// fetch requests the next page of rows
+func (it *Iterator) fetch() error {
+	size := it.pageSize
+	if it.remaining >= 0 && it.remaining < size {
+		size = it.remaining
+	}
+
+	values := it.query.Values()
+	values.Del("limit")
+	values.Del("offset")
+	header := cloneHeader(it.query.requestHeader())
+	header.Set("Range-Unit", "items")
+	header.Set("Range", fmt.Sprintf("%d-%d", it.start, it.start+size-1))
+
+	agent := it.query.agent
+	response, err := agent.send(it.ctx, http.MethodGet, it.query.table, values, header, nil)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		response.Body.Close()
+		it.done = true
+		return nil
+	}
+	result, err := newResult(response, &it.rows)
+	if err != nil {
+		return err
+	}
+
+	returned := int64(len(it.rows))
+	it.start += returned
+	if it.remaining > 0 {
+		it.remaining -= returned
+	}
+	if result.Range.Total >= 0 {
+		it.total = result.Range.Total
+	}
+	if returned < size || (it.total >= 0 && it.start >= it.total) {
+		it.done = true
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package postgrest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+func newIterServer(rows int, requests *int64) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(requests, 1)
+		if r.URL.Query().Get("error") != "" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		var start, end int
+		if _, err := fmt.Sscanf(r.Header.Get("Range"), "%d-%d", &start, &end); err != nil || r.Header.Get("Range-Unit") != "items" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if start >= rows {
+			w.Header().Set("Content-Range", fmt.Sprintf("*/%d", rows))
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		if end >= rows {
+			end = rows - 1
+		}
+		total := "*"
+		if r.Header.Get("Prefer") == "count=exact" {
+			total = fmt.Sprint(rows)
+		}
+		page := []map[string]int{}
+		for i := start; i <= end; i++ {
+			page = append(page, map[string]int{"id": i})
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("%d-%d/%s", start, end, total))
+		w.WriteHeader(http.StatusPartialContent)
+		json.NewEncoder(w).Encode(page)
+	}))
+}
+
+func TestIterator(t *testing.T) {
+	t.Parallel()
+
+	var requests int64
+	iterServer := newIterServer(7, &requests)
+	defer iterServer.Close()
+	testAgent := newTestAgent(iterServer.URL)
+
+	var tests = []struct {
+		query            *Query
+		pageSize         int
+		expectedIDs      []int
+		expectedRequests int64
+		expectedTotal    int64
+	}{
+		{testAgent.From("events"), 3, []int{0, 1, 2, 3, 4, 5, 6}, 3, -1},
+		{testAgent.From("events"), 7, []int{0, 1, 2, 3, 4, 5, 6}, 2, -1},
+		{testAgent.From("events").Count(CountExact), 7, []int{0, 1, 2, 3, 4, 5, 6}, 1, 7},
+		{testAgent.From("events").Offset(2).Limit(4), 3, []int{2, 3, 4, 5}, 2, -1},
+		{testAgent.From("events").Offset(10), 3, []int{}, 1, -1},
+	}
+	for _, test := range tests {
+		atomic.StoreInt64(&requests, 0)
+		ids := []int{}
+		it := test.query.Iter(context.Background(), test.pageSize)
+		for it.Next() {
+			row := struct {
+				ID int `json:"id"`
+			}{}
+			if err := it.Scan(&row); err != nil {
+				t.Errorf("Scan returned unexpected error: %v", err)
+			}
+			ids = append(ids, row.ID)
+		}
+		if err := it.Err(); err != nil {
+			t.Errorf("Err returned unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(ids, test.expectedIDs) {
+			t.Errorf("Iterator returned unexpected rows:\nExpected: %v\nGot: %v", test.expectedIDs, ids)
+		}
+		if got := atomic.LoadInt64(&requests); got != test.expectedRequests {
+			t.Errorf("Iterator made unexpected number of requests:\nExpected: %d\nGot: %d", test.expectedRequests, got)
+		}
+		if it.Total() != test.expectedTotal {
+			t.Errorf("Total returned unexpected total:\nExpected: %d\nGot: %d", test.expectedTotal, it.Total())
+		}
+		if err := it.Scan(&struct{}{}); err == nil || err.Error() != errNoCurrentRow.Error() {
+			t.Errorf("Scan returned unexpected error:\nExpected: %v\nGot: %v", errNoCurrentRow, err)
+		}
+	}
+
+	it := testAgent.From("events").Eq("error", 1).Iter(context.Background(), 3)
+	if it.Next() {
+		t.Error("Next returned unexpected row")
+	}
+	if _, ok := it.Err().(*Error); !ok {
+		t.Errorf("Err returned unexpected error:\nExpected: %T\nGot: %v", &Error{}, it.Err())
+	}
+
+	it = testAgent.From("events").Iter(context.Background(), 0)
+	if it.Next() || it.Err() == nil || it.Err().Error() != errInvalidPageSize.Error() {
+		t.Errorf("Err returned unexpected error:\nExpected: %v\nGot: %v", errInvalidPageSize, it.Err())
+	}
+}
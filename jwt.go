@@ -0,0 +1,157 @@
+package postgrest
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"hash"
+)
+
+// jwtGenerators are the built-in JWTGenerators by signing algorithm
+var jwtGenerators = map[string]JWTGenerator{
+	"HS256": HS256,
+	"HS384": HS384,
+	"HS512": HS512,
+	"RS256": RS256,
+	"ES256": ES256,
+}
+
+// HS256 is a JWTGenerator signing the claims with HMAC SHA-256 using secret as the key
+func HS256(claims interface{}, secret string) (string, error) {
+	return signHMAC("HS256", sha256.New, claims, secret)
+}
+
+// HS384 is a JWTGenerator signing the claims with HMAC SHA-384 using secret as the key
+func HS384(claims interface{}, secret string) (string, error) {
+	return signHMAC("HS384", sha512.New384, claims, secret)
+}
+
+// HS512 is a JWTGenerator signing the claims with HMAC SHA-512 using secret as the key
+func HS512(claims interface{}, secret string) (string, error) {
+	return signHMAC("HS512", sha512.New, claims, secret)
+}
+
+// RS256 is a JWTGenerator signing the claims with RSA PKCS #1 v1.5 SHA-256.
+// secret is a PEM encoded PKCS #1 or PKCS #8 RSA private key.
+func RS256(claims interface{}, secret string) (string, error) {
+	key, err := parsePrivateKey(secret)
+	if err != nil {
+		return "", err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return "", errInvalidPrivateKey
+	}
+	return signJWT("RS256", "", claims, rsaSigner(rsaKey))
+}
+
+// ES256 is a JWTGenerator signing the claims with ECDSA P-256 SHA-256.
+// secret is a PEM encoded SEC 1 or PKCS #8 EC private key.
+func ES256(claims interface{}, secret string) (string, error) {
+	key, err := parsePrivateKey(secret)
+	if err != nil {
+		return "", err
+	}
+	ecKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok || ecKey.Curve != elliptic.P256() {
+		return "", errInvalidPrivateKey
+	}
+	return signJWT("ES256", "", claims, ecdsaSigner(ecKey))
+}
+
+// signer signs the signing input of a JSON Web Token
+type signer func(signingInput []byte) ([]byte, error)
+
+// signHMAC signs the claims with the HMAC algorithm alg
+func signHMAC(alg string, h func() hash.Hash, claims interface{}, secret string) (string, error) {
+	return signJWT(alg, "", claims, hmacSigner(h, []byte(secret)))
+}
+
+// hmacSigner returns a signer computing the HMAC of the signing input with key
+func hmacSigner(h func() hash.Hash, key []byte) signer {
+	return func(signingInput []byte) ([]byte, error) {
+		mac := hmac.New(h, key)
+		mac.Write(signingInput)
+		return mac.Sum(nil), nil
+	}
+}
+
+// rsaSigner returns a signer using RSA PKCS #1 v1.5 SHA-256
+func rsaSigner(key *rsa.PrivateKey) signer {
+	return func(signingInput []byte) ([]byte, error) {
+		digest := sha256.Sum256(signingInput)
+		return rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	}
+}
+
+// ecdsaSigner returns a signer using ECDSA SHA-256
+func ecdsaSigner(key *ecdsa.PrivateKey) signer {
+	return func(signingInput []byte) ([]byte, error) {
+		return signECDSA(key, signingInput)
+	}
+}
+
+// signECDSA signs signingInput with ECDSA SHA-256 returning the fixed size r || s signature used by JWS
+func signECDSA(key *ecdsa.PrivateKey, signingInput []byte) ([]byte, error) {
+	digest := sha256.Sum256(signingInput)
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+	size := (key.Curve.Params().BitSize + 7) / 8
+	signature := make([]byte, 2*size)
+	rBytes, sBytes := r.Bytes(), s.Bytes()
+	copy(signature[size-len(rBytes):size], rBytes)
+	copy(signature[2*size-len(sBytes):], sBytes)
+	return signature, nil
+}
+
+// signJWT encodes the claims as a JSON Web Token signed with the given sign function.
+// kid identifies the signing key in the header when not empty.
+func signJWT(alg, kid string, claims interface{}, sign signer) (string, error) {
+	header := struct {
+		Alg string `json:"alg"`
+		Typ string `json:"typ"`
+		Kid string `json:"kid,omitempty"`
+	}{alg, "JWT", kid}
+	headerBytes, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	claimsBytes, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(headerBytes) + "." + base64.RawURLEncoding.EncodeToString(claimsBytes)
+	signature, err := sign([]byte(signingInput))
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// parsePrivateKey parses a PEM encoded RSA or EC private key
+func parsePrivateKey(secret string) (interface{}, error) {
+	block, _ := pem.Decode([]byte(secret))
+	if block == nil {
+		return nil, errInvalidPrivateKey
+	}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	return nil, errInvalidPrivateKey
+}
@@ -0,0 +1,139 @@
+package postgrest
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"hash"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// decodeJWT splits a token into its decoded header, claims and signature
+func decodeJWT(t *testing.T, token string) (map[string]string, *Claims, string, []byte) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("token has unexpected number of parts:\nExpected: %d\nGot: %d", 3, len(parts))
+	}
+	header := map[string]string{}
+	claims := &Claims{}
+	headerBytes, _ := base64.RawURLEncoding.DecodeString(parts[0])
+	claimsBytes, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	if err := json.Unmarshal(headerBytes, &header); err != nil {
+		t.Errorf("token header could not be decoded: %v", err)
+	}
+	if err := json.Unmarshal(claimsBytes, claims); err != nil {
+		t.Errorf("token claims could not be decoded: %v", err)
+	}
+	return header, claims, parts[0] + "." + parts[1], signature
+}
+
+func TestHMACGenerators(t *testing.T) {
+	t.Parallel()
+
+	claims := generateClaims("role", &Config{Issuer: "test", Timeout: 5})
+	var tests = []struct {
+		alg       string
+		generator JWTGenerator
+		hash      func() hash.Hash
+	}{
+		{"HS256", HS256, sha256.New},
+		{"HS384", HS384, sha512.New384},
+		{"HS512", HS512, sha512.New},
+	}
+	for _, test := range tests {
+		token, err := test.generator(claims, "secret")
+		if err != nil {
+			t.Errorf("%s returned unexpected error: %v", test.alg, err)
+		}
+		header, decoded, signingInput, signature := decodeJWT(t, token)
+		expectedHeader := map[string]string{"alg": test.alg, "typ": "JWT"}
+		if !reflect.DeepEqual(header, expectedHeader) {
+			t.Errorf("%s returned unexpected header:\nExpected: %v\nGot: %v", test.alg, expectedHeader, header)
+		}
+		if !reflect.DeepEqual(decoded, claims) {
+			t.Errorf("%s returned unexpected claims:\nExpected: %v\nGot: %v", test.alg, claims, decoded)
+		}
+		mac := hmac.New(test.hash, []byte("secret"))
+		mac.Write([]byte(signingInput))
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			t.Errorf("%s returned invalid signature", test.alg)
+		}
+	}
+}
+
+func TestRS256(t *testing.T) {
+	t.Parallel()
+
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	pkcs8, _ := x509.MarshalPKCS8PrivateKey(key)
+	claims := generateClaims("role", &Config{Issuer: "test", Timeout: 5})
+	for _, secret := range []string{
+		string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8})),
+	} {
+		token, err := RS256(claims, secret)
+		if err != nil {
+			t.Errorf("RS256 returned unexpected error: %v", err)
+			continue
+		}
+		header, decoded, signingInput, signature := decodeJWT(t, token)
+		if header["alg"] != "RS256" || !reflect.DeepEqual(decoded, claims) {
+			t.Errorf("RS256 returned unexpected token:\nExpected: %s %v\nGot: %s %v", "RS256", claims, header["alg"], decoded)
+		}
+		digest := sha256.Sum256([]byte(signingInput))
+		if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+			t.Errorf("RS256 returned invalid signature: %v", err)
+		}
+	}
+
+	for _, secret := range []string{"secret", string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: []byte{}}))} {
+		if _, err := RS256(claims, secret); err == nil || err.Error() != errInvalidPrivateKey.Error() {
+			t.Errorf("RS256 returned unexpected error:\nExpected: %v\nGot: %v", errInvalidPrivateKey, err)
+		}
+	}
+}
+
+func TestES256(t *testing.T) {
+	t.Parallel()
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	der, _ := x509.MarshalECPrivateKey(key)
+	secret := string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}))
+	claims := generateClaims("role", &Config{Issuer: "test", Timeout: 5})
+
+	token, err := ES256(claims, secret)
+	if err != nil {
+		t.Fatalf("ES256 returned unexpected error: %v", err)
+	}
+	header, decoded, signingInput, signature := decodeJWT(t, token)
+	if header["alg"] != "ES256" || !reflect.DeepEqual(decoded, claims) {
+		t.Errorf("ES256 returned unexpected token:\nExpected: %s %v\nGot: %s %v", "ES256", claims, header["alg"], decoded)
+	}
+	if len(signature) != 64 {
+		t.Fatalf("ES256 returned unexpected signature length:\nExpected: %d\nGot: %d", 64, len(signature))
+	}
+	digest := sha256.Sum256([]byte(signingInput))
+	r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+	if !ecdsa.Verify(&key.PublicKey, digest[:], r, s) {
+		t.Error("ES256 returned invalid signature")
+	}
+
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 1024)
+	rsaSecret := string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}))
+	if _, err := ES256(claims, rsaSecret); err == nil || err.Error() != errInvalidPrivateKey.Error() {
+		t.Errorf("ES256 returned unexpected error:\nExpected: %v\nGot: %v", errInvalidPrivateKey, err)
+	}
+}
@@ -0,0 +1,144 @@
+package postgrest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// KeyColumn is a column of the ordered key used for keyset pagination
+type KeyColumn struct {
+	Column    string
+	Direction Direction
+}
+
+// descending returns true if the key column is sorted in descending order
+func (k KeyColumn) descending() bool {
+	return strings.HasPrefix(string(k.Direction), string(Desc))
+}
+
+// KeysetPage fetches the page of at most pageSize rows following cursor, ordered by the given key columns,
+// and unmarshals it into target. An empty cursor fetches the first page.
+// It returns the opaque cursor of the next page, which is empty when there are no more rows.
+// The key columns must be returned in the response, not null and unique together. They replace the
+// order of the query and the rows are filtered with e.g: or=(a.gt.x,and(a.eq.x,b.gt.y)) for (a,b) > (x,y)
+func (q *Query) KeysetPage(cursor string, pageSize int, target interface{}, keys ...KeyColumn) (string, error) {
+	if len(keys) == 0 {
+		return "", errMissingKeyColumns
+	}
+	if pageSize <= 0 {
+		return "", errInvalidPageSize
+	}
+
+	page := *q
+	page.filters = append([]Filter{}, q.filters...)
+	page.order = nil
+	page.limit = pageSize
+	page.offset = -1
+	for _, key := range keys {
+		direction := key.Direction
+		if direction == "" {
+			direction = Asc
+		}
+		page.order = append(page.order, orderItem(key.Column, direction))
+	}
+	if cursor != "" {
+		values, err := decodeCursor(cursor, len(keys))
+		if err != nil {
+			return "", err
+		}
+		page.filters = append(page.filters, keysetFilter(keys, values))
+	}
+
+	response, err := page.get(target)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	if !isSuccess(response.StatusCode) {
+		return "", newError(response)
+	}
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return "", err
+	}
+	var rows []map[string]json.RawMessage
+	if err := json.Unmarshal(body, &rows); err != nil {
+		return "", err
+	}
+	if target != nil {
+		if err := json.Unmarshal(body, target); err != nil {
+			return "", err
+		}
+	}
+	if len(rows) < pageSize {
+		return "", nil
+	}
+	return encodeCursor(rows[len(rows)-1], keys)
+}
+
+// keysetFilter returns the filter matching the rows following values in the order of keys
+func keysetFilter(keys []KeyColumn, values []string) Filter {
+	branches := make([]Filter, len(keys))
+	for i, key := range keys {
+		conditions := make([]Filter, 0, i+1)
+		for j := 0; j < i; j++ {
+			conditions = append(conditions, Eq(keys[j].Column, values[j]))
+		}
+		if key.descending() {
+			conditions = append(conditions, Lt(key.Column, values[i]))
+		} else {
+			conditions = append(conditions, Gt(key.Column, values[i]))
+		}
+		if len(conditions) == 1 {
+			branches[i] = conditions[0]
+		} else {
+			branches[i] = And(conditions...)
+		}
+	}
+	if len(branches) == 1 {
+		return branches[0]
+	}
+	return Or(branches...)
+}
+
+// encodeCursor encodes the key column values of row as an opaque cursor
+func encodeCursor(row map[string]json.RawMessage, keys []KeyColumn) (string, error) {
+	values := make([]json.RawMessage, len(keys))
+	for i, key := range keys {
+		value, ok := row[key.Column]
+		if !ok || string(value) == "null" {
+			return "", fmt.Errorf("postgrest error: key column '%s' missing in keyset page", key.Column)
+		}
+		values[i] = value
+	}
+	b, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// decodeCursor decodes the key column values of a cursor into their postgREST representations
+func decodeCursor(cursor string, keys int) ([]string, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	var raw []json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil || len(raw) != keys {
+		return nil, errInvalidCursor
+	}
+	values := make([]string, keys)
+	for i, value := range raw {
+		var str string
+		if err := json.Unmarshal(value, &str); err == nil {
+			values[i] = str
+			continue
+		}
+		values[i] = string(value)
+	}
+	return values, nil
+}
@@ -0,0 +1,102 @@
+package postgrest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestKeysetFilter(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		keys          []KeyColumn
+		values        []string
+		expectedKey   string
+		expectedValue string
+	}{
+		{[]KeyColumn{{"id", Asc}}, []string{"10"}, "id", "gt.10"},
+		{[]KeyColumn{{"id", DescNullsLast}}, []string{"10"}, "id", "lt.10"},
+		{
+			[]KeyColumn{{"a", ""}, {"b", Desc}, {"c", Asc}},
+			[]string{"1", "x,y", "3"},
+			"or",
+			`(a.gt.1,and(a.eq.1,b.lt."x,y"),and(a.eq.1,b.eq."x,y",c.gt.3))`,
+		},
+	}
+	for _, test := range tests {
+		filter := keysetFilter(test.keys, test.values)
+		if filter.key() != test.expectedKey || filter.value() != test.expectedValue {
+			t.Errorf("keysetFilter returned unexpected filter:\nExpected: %s=%s\nGot: %s=%s",
+				test.expectedKey, test.expectedValue, filter.key(), filter.value())
+		}
+	}
+}
+
+func TestKeysetPage(t *testing.T) {
+	t.Parallel()
+
+	var queries []url.Values
+	keysetServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.Query())
+		if r.URL.Query().Get("or") == "" {
+			fmt.Fprint(w, `[{"a":1,"b":"x"},{"a":1,"b":"y,z"}]`)
+			return
+		}
+		fmt.Fprint(w, `[{"a":2,"b":"q"}]`)
+	}))
+	defer keysetServer.Close()
+	testAgent := newTestAgent(keysetServer.URL)
+
+	type row struct {
+		A int    `json:"a"`
+		B string `json:"b"`
+	}
+	keys := []KeyColumn{{"a", Asc}, {"b", Desc}}
+	query := testAgent.From("events").Eq("kind", "click").Order("ignored", Asc).Offset(5)
+
+	rows := []row{}
+	cursor, err := query.KeysetPage("", 2, &rows, keys...)
+	if err != nil {
+		t.Errorf("KeysetPage returned unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(rows, []row{{1, "x"}, {1, "y,z"}}) || cursor == "" {
+		t.Errorf("KeysetPage returned unexpected page:\nExpected: %v\nGot: %v %q", []row{{1, "x"}, {1, "y,z"}}, rows, cursor)
+	}
+
+	cursor, err = query.KeysetPage(cursor, 2, &rows, keys...)
+	if err != nil {
+		t.Errorf("KeysetPage returned unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(rows, []row{{2, "q"}}) || cursor != "" {
+		t.Errorf("KeysetPage returned unexpected page:\nExpected: %v\nGot: %v %q", []row{{2, "q"}}, rows, cursor)
+	}
+
+	expected := []url.Values{
+		{"select": {"a,b"}, "kind": {"eq.click"}, "order": {"a.asc,b.desc"}, "limit": {"2"}},
+		{"select": {"a,b"}, "kind": {"eq.click"}, "order": {"a.asc,b.desc"}, "limit": {"2"}, "or": {`(a.gt.1,and(a.eq.1,b.lt."y,z"))`}},
+	}
+	if !reflect.DeepEqual(queries, expected) {
+		t.Errorf("KeysetPage made unexpected requests:\nExpected: %v\nGot: %v", expected, queries)
+	}
+
+	if _, err = query.KeysetPage("", 2, &rows); err == nil || err.Error() != errMissingKeyColumns.Error() {
+		t.Errorf("KeysetPage returned unexpected error:\nExpected: %v\nGot: %v", errMissingKeyColumns, err)
+	}
+	if _, err = query.KeysetPage("", 0, &rows, keys...); err == nil || err.Error() != errInvalidPageSize.Error() {
+		t.Errorf("KeysetPage returned unexpected error:\nExpected: %v\nGot: %v", errInvalidPageSize, err)
+	}
+	for _, invalid := range []string{"!", "bnVsbA", "WzFd"} {
+		if _, err = query.KeysetPage(invalid, 2, &rows, keys...); err == nil || err.Error() != errInvalidCursor.Error() {
+			t.Errorf("KeysetPage returned unexpected error:\nExpected: %v\nGot: %v", errInvalidCursor, err)
+		}
+	}
+
+	expectedError := "postgrest error: key column 'c' missing in keyset page"
+	if _, err = query.KeysetPage("", 2, nil, KeyColumn{"c", Asc}); err == nil || err.Error() != expectedError {
+		t.Errorf("KeysetPage returned unexpected error:\nExpected: %v\nGot: %v", expectedError, err)
+	}
+}
@@ -0,0 +1,133 @@
+package postgrest
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limits configures the client-side rate limit and concurrency cap of master or slave traffic
+type Limits struct {
+	// Rate is the number of requests allowed per second, unlimited when 0
+	Rate float64 `yaml:"rate,omitempty"`
+	// Burst is the number of requests allowed at once by the rate limit (1 by default)
+	Burst int `yaml:"burst,omitempty"`
+	// MaxInFlight is the maximum number of requests whose response body is not closed yet, unlimited when 0
+	MaxInFlight int `yaml:"max_in_flight,omitempty"`
+}
+
+// limiter enforces Limits with a token bucket and a semaphore of in-flight requests
+type limiter struct {
+	rate     float64
+	burst    float64
+	inFlight chan struct{}
+	mu       sync.Mutex
+	tokens   float64
+	last     time.Time
+}
+
+// newLimiter returns the limiter enforcing limits or nil when limits is nil
+func newLimiter(limits *Limits) *limiter {
+	if limits == nil {
+		return nil
+	}
+	l := &limiter{rate: limits.Rate, burst: math.Max(1, float64(limits.Burst)), last: time.Now()}
+	l.tokens = l.burst
+	if limits.MaxInFlight > 0 {
+		l.inFlight = make(chan struct{}, limits.MaxInFlight)
+	}
+	return l
+}
+
+// refill adds the tokens accumulated since the last refill to the bucket. It must be called with the limiter locked.
+func (l *limiter) refill(now time.Time) {
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+}
+
+// reserve takes a token from the bucket and returns how long to wait before it is available
+func (l *limiter) reserve(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill(now)
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// unreserve gives back a reserved token
+func (l *limiter) unreserve() {
+	l.mu.Lock()
+	l.tokens = math.Min(l.burst, l.tokens+1)
+	l.mu.Unlock()
+}
+
+// wait blocks until the limiter allows a request or ctx is done and returns the function releasing
+// its in-flight slot
+func (l *limiter) wait(ctx context.Context) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	if l.rate > 0 {
+		if delay := l.reserve(time.Now()); delay > 0 {
+			timer := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				l.unreserve()
+				return nil, ctx.Err()
+			case <-timer.C:
+			}
+		}
+	}
+	if l.inFlight == nil {
+		return func() {}, nil
+	}
+	select {
+	case l.inFlight <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return l.releaser(), nil
+}
+
+// tryAcquire returns the function releasing the in-flight slot of a request if the limiter allows it
+// without waiting
+func (l *limiter) tryAcquire() (func(), bool) {
+	if l == nil {
+		return func() {}, true
+	}
+	if l.rate > 0 {
+		l.mu.Lock()
+		l.refill(time.Now())
+		if l.tokens < 1 {
+			l.mu.Unlock()
+			return nil, false
+		}
+		l.tokens--
+		l.mu.Unlock()
+	}
+	if l.inFlight == nil {
+		return func() {}, true
+	}
+	select {
+	case l.inFlight <- struct{}{}:
+		return l.releaser(), true
+	default:
+		if l.rate > 0 {
+			l.unreserve()
+		}
+		return nil, false
+	}
+}
+
+// releaser returns the function releasing an in-flight slot once
+func (l *limiter) releaser() func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() { <-l.inFlight })
+	}
+}
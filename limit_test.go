@@ -0,0 +1,96 @@
+package postgrest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	t.Parallel()
+
+	if release, err := newLimiter(nil).wait(context.Background()); err != nil || release == nil {
+		t.Errorf("wait returned unexpected result for a nil limiter: %v", err)
+	}
+
+	rateLimiter := newLimiter(&Limits{Rate: 100, Burst: 2})
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		if _, err := rateLimiter.wait(context.Background()); err != nil {
+			t.Errorf("wait returned unexpected error: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 35*time.Millisecond {
+		t.Errorf("wait did not enforce the rate:\nExpected: >= %v\nGot: %v", 35*time.Millisecond, elapsed)
+	}
+	if _, ok := rateLimiter.tryAcquire(); ok {
+		t.Error("tryAcquire acquired an empty bucket")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if _, err := newLimiter(&Limits{Rate: 0.001}).wait(ctx); err != nil {
+		t.Errorf("wait returned unexpected error for the burst: %v", err)
+	}
+	slow := newLimiter(&Limits{Rate: 0.001})
+	slow.wait(context.Background())
+	if _, err := slow.wait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("wait returned unexpected error:\nExpected: %v\nGot: %v", context.DeadlineExceeded, err)
+	}
+
+	inFlight := newLimiter(&Limits{MaxInFlight: 1})
+	release, ok := inFlight.tryAcquire()
+	if !ok {
+		t.Fatal("tryAcquire did not acquire a free slot")
+	}
+	if _, ok := inFlight.tryAcquire(); ok {
+		t.Error("tryAcquire acquired more slots than MaxInFlight")
+	}
+	if _, err := inFlight.wait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("wait returned unexpected error:\nExpected: %v\nGot: %v", context.DeadlineExceeded, err)
+	}
+	release()
+	release()
+	if _, ok := inFlight.tryAcquire(); !ok {
+		t.Error("tryAcquire did not acquire a released slot")
+	}
+	if len(inFlight.inFlight) != 1 {
+		t.Errorf("limiter has unexpected number of in-flight requests:\nExpected: %d\nGot: %d", 1, len(inFlight.inFlight))
+	}
+}
+
+func TestConfigLimits(t *testing.T) {
+	t.Parallel()
+
+	servers := newReplicaServers("master", "slave")
+	defer servers.close()
+	testAgent, _ := NewAgent(&Config{
+		MasterBaseURL: servers.servers["master"].URL,
+		MasterRole:    "master",
+		MasterSecret:  "master_secret",
+		SlaveBaseURL:  servers.servers["slave"].URL,
+		SlaveRole:     "slave",
+		SlaveSecret:   "slave_secret",
+		Timeout:       5,
+		MasterLimits:  &Limits{MaxInFlight: 1},
+	}, &http.Client{}, nil)
+
+	response, err := testAgent.Post("test_table", nil)
+	if err != nil {
+		t.Fatalf("Post returned unexpected error: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := testAgent.PostContext(ctx, "test_table", nil); err != context.DeadlineExceeded {
+		t.Errorf("PostContext returned unexpected error:\nExpected: %v\nGot: %v", context.DeadlineExceeded, err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := testAgent.GetJSON("test_table", nil, nil); err != nil {
+			t.Errorf("GetJSON returned unexpected error: %v", err)
+		}
+	}
+	response.Body.Close()
+	if _, err := testAgent.PostJSON("test_table", map[string]string{}, nil); err != nil {
+		t.Errorf("PostJSON returned unexpected error: %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package postgrest
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -15,18 +16,32 @@ import (
 
 // errors
 var (
-	errMissingConfigParams  = errors.New("postgrest error: missing config parameter")
-	errMissingHTTPClient    = errors.New("postgrest error: missing httpClient parameter")
-	errMissingJWTGenerator  = errors.New("postgrest error: missing jwtGenerator parameter")
-	errMissingRequestMethod = errors.New("postgrest error: missing request method")
-	errMissingRequestURL    = errors.New("postgrest error: missing request url")
-	errMissingURLPath       = errors.New("postgrest error: table name not specified in request")
-	errMissingRoleClaim     = errors.New("postgrest error: missing 'role' in postgrest claims")
-	errInvalidExpiryClaim   = errors.New("postgrest error: invalid 'exp' in postgrest claims")
+	errMissingConfigParams      = errors.New("postgrest error: missing config parameter")
+	errMissingHTTPClient        = errors.New("postgrest error: missing httpClient parameter")
+	errMissingRequestMethod     = errors.New("postgrest error: missing request method")
+	errMissingRequestURL        = errors.New("postgrest error: missing request url")
+	errMissingURLPath           = errors.New("postgrest error: table name not specified in request")
+	errMissingFunctionName      = errors.New("postgrest error: function name not specified in request")
+	errMissingRoleClaim         = errors.New("postgrest error: missing 'role' in postgrest claims")
+	errInvalidExpiryClaim       = errors.New("postgrest error: invalid 'exp' in postgrest claims")
+	errInvalidSelectTarget      = errors.New("postgrest error: select target must be a struct or a slice of structs")
+	errInvalidContentRange      = errors.New("postgrest error: invalid Content-Range header")
+	errInvalidPageSize          = errors.New("postgrest error: page size must be greater than 0")
+	errNoCurrentRow             = errors.New("postgrest error: Scan called without calling Next")
+	errMissingKeyColumns        = errors.New("postgrest error: keyset pagination requires at least one key column")
+	errInvalidCursor            = errors.New("postgrest error: invalid keyset cursor")
+	errUnsupportedAlgorithm     = errors.New("postgrest error: unsupported jwt signing algorithm")
+	errInvalidPrivateKey        = errors.New("postgrest error: invalid PEM encoded private key")
+	errInvalidTokenRefreshRatio = errors.New("postgrest error: token refresh ratio must be between 0 and 1, exclusive")
+	errMalformedBearerToken     = errors.New("postgrest error: malformed bearer token")
+	errExpiredBearerToken       = errors.New("postgrest error: bearer token has expired")
+	errMissingTokenSource       = errors.New("postgrest error: missing token source parameter")
+	errMissingSigningKey        = errors.New("postgrest error: no supported private key in JWKS")
 )
 
 // Config contains config data for making postgREST calls
 type Config struct {
+	Algorithm     string        `yaml:"algorithm,omitempty"`
 	Issuer        string        `yaml:"issuer,omitempty"`
 	MasterBaseURL string        `yaml:"master_base_url" required:"true"`
 	MasterRole    string        `yaml:"master_role" required:"true"`
@@ -35,6 +50,33 @@ type Config struct {
 	SlaveRole     string        `yaml:"slave_role" required:"true"`
 	SlaveSecret   string        `yaml:"slave_secret" required:"true"`
 	Timeout       time.Duration `yaml:"timeout" required:"true"`
+	// TokenRefreshRatio is the fraction of Timeout, less than 1, after which a cached token is signed again (0.5 by default)
+	TokenRefreshRatio float64 `yaml:"token_refresh_ratio,omitempty"`
+	// Replicas are read replicas serving GET requests along with SlaveBaseURL
+	Replicas []Replica `yaml:"replicas,omitempty"`
+	// EjectionThreshold is the number of consecutive failures after which an endpoint is ejected (3 by default)
+	EjectionThreshold int `yaml:"ejection_threshold,omitempty"`
+	// EjectionTimeout is the duration an endpoint is ejected for, e.g: 30 * time.Second (the default)
+	EjectionTimeout time.Duration `yaml:"ejection_timeout,omitempty"`
+	// FallbackToMaster sends reads to the master, signed with the master role, when the replicas are unavailable
+	FallbackToMaster bool `yaml:"fallback_to_master,omitempty"`
+	// Balancer is the strategy balancing reads between SlaveBaseURL and the replicas (RoundRobin by default)
+	Balancer Balancer `yaml:"-"`
+	// RetryPolicy configures the retries of failed requests (DefaultRetryPolicy by default)
+	RetryPolicy *RetryPolicy `yaml:"-"`
+	// CircuitBreaker configures a circuit breaker on the master and on each replica, disabled when nil
+	CircuitBreaker *BreakerSettings `yaml:"-"`
+	// MasterLimits limits the requests sent to the master, unlimited when nil
+	MasterLimits *Limits `yaml:"master_limits,omitempty"`
+	// SlaveLimits limits the requests sent to SlaveBaseURL and the replicas together, unlimited when nil
+	SlaveLimits *Limits `yaml:"slave_limits,omitempty"`
+	// HedgeDelay is the delay, e.g: 50 * time.Millisecond, after which a read that has not been answered
+	// by its replica is also sent to another replica, disabled when 0
+	HedgeDelay time.Duration `yaml:"hedge_delay,omitempty"`
+	// Deduplicate makes GET requests wait for the response of an identical request in flight instead of being sent
+	Deduplicate bool `yaml:"deduplicate,omitempty"`
+	// ResponseCache caches the responses of GET requests, e.g: NewLRUCache, disabled when nil
+	ResponseCache ResponseCache `yaml:"-"`
 }
 
 // isSuccess returns true if the http status code is inclusively between 200 and 300
@@ -50,11 +92,12 @@ func jsonEncode(payload interface{}) (io.Reader, error) {
 }
 
 // unmarshalResponse unmarshals the body of an http.Response object into the target interface
+// Returns an *Error if the response status code is not inclusively between 200 and 299
 func unmarshalResponse(response *http.Response, target interface{}) (int, error) {
 	defer response.Body.Close()
 
 	if !isSuccess(response.StatusCode) {
-		return response.StatusCode, fmt.Errorf("postgrest error (%s %s): %s", response.Request.Method, response.Request.URL.String(), response.Status)
+		return response.StatusCode, newError(response)
 	}
 	if target == nil {
 		return response.StatusCode, nil
@@ -75,13 +118,13 @@ func generateClaims(role string, config *Config) *Claims {
 	}
 }
 
-func newRequest(method, urlStr, tokenStr string, body io.Reader) (*http.Request, error) {
+func newRequest(ctx context.Context, method, urlStr, tokenStr string, body io.Reader) (*http.Request, error) {
 	request, err := http.NewRequest(method, urlStr, body)
 	if err != nil {
 		return nil, err
 	}
 	request.Header.Add("Authorization", tokenStr)
-	return request, nil
+	return request.WithContext(ctx), nil
 }
 
 // buildURL return a *url.URL object from the given `baseURL`, `path` and `queryParams`
@@ -104,7 +147,7 @@ func buildURLStr(baseURL, path string, queryParams *url.Values) (string, error)
 }
 
 // validateConfig ensures that all required data is available in Config
-func validateConfig(config *Config) error {
+func validateConfig(config *Config, optional ...string) error {
 	var invalidFields []string
 
 	valueData := reflect.ValueOf(config).Elem()
@@ -117,6 +160,9 @@ func validateConfig(config *Config) error {
 		if required, ok := fieldType.Tag.Lookup("required"); !ok || required != "true" {
 			continue
 		}
+		if isOptional(fieldType.Name, optional) {
+			continue
+		}
 
 		if fieldValue.Type().String() == "string" && fieldValue.String() == "" {
 			invalidFields = append(invalidFields, fieldType.Name)
@@ -126,6 +172,11 @@ func validateConfig(config *Config) error {
 			invalidFields = append(invalidFields, fieldType.Name)
 		}
 	}
+	for i, replica := range config.Replicas {
+		if !isBaseURL(replica.BaseURL) {
+			invalidFields = append(invalidFields, fmt.Sprintf("Replicas[%d].BaseURL", i))
+		}
+	}
 
 	if invalidFields != nil {
 		return fmt.Errorf("postgrest error: invalid config parameters: \n- %s",
@@ -135,11 +186,54 @@ func validateConfig(config *Config) error {
 	return nil
 }
 
+// isBaseURL returns true if baseURL is an absolute url with a scheme and a host
+func isBaseURL(baseURL string) bool {
+	u, err := url.Parse(baseURL)
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
+
+// isOptional returns true if the config field name is one of the optional fields
+func isOptional(name string, optional []string) bool {
+	for _, field := range optional {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
+
 // Claims contains data necessary to make a postgREST claims
 type Claims struct {
 	Role      string `json:"role,omitempty"`
 	Issuer    string `json:"iss,omitempty"`
 	ExpiresAt int64  `json:"exp,omitempty"`
+	// Extra contains additional claims, e.g: user or tenant ids, available to postgREST in request.jwt.claims
+	Extra map[string]interface{} `json:"-"`
+}
+
+// MarshalJSON encodes the claims merged with the extra claims, which cannot override role, iss or exp
+func (c Claims) MarshalJSON() ([]byte, error) {
+	type claims Claims
+	if len(c.Extra) == 0 {
+		return json.Marshal(claims(c))
+	}
+	merged := make(map[string]interface{}, len(c.Extra)+3)
+	for name, value := range c.Extra {
+		merged[name] = value
+	}
+	delete(merged, "role")
+	delete(merged, "iss")
+	delete(merged, "exp")
+	if c.Role != "" {
+		merged["role"] = c.Role
+	}
+	if c.Issuer != "" {
+		merged["iss"] = c.Issuer
+	}
+	if c.ExpiresAt != 0 {
+		merged["exp"] = c.ExpiresAt
+	}
+	return json.Marshal(merged)
 }
 
 // Valid ensures that the given claims are valid
@@ -162,8 +256,11 @@ type HTTPClientAdapter interface {
 type PgrestAdapter interface {
 	Delete(table string, query *url.Values) (*http.Response, error)
 	DeleteJSON(table string, query *url.Values) (int, error)
+	From(table string) *Query
+	Function(fn string) *Query
 	Get(table string, query *url.Values) (*http.Response, error)
 	GetJSON(table string, query *url.Values, target interface{}) (int, error)
+	GetResult(table string, query *url.Values, count Count, target interface{}) (*Result, error)
 	NewRequest(method, urlStr string, body io.Reader) (*http.Request, error)
 	Patch(table string, query *url.Values, body io.Reader) (*http.Response, error)
 	PatchJSON(table string, query *url.Values, payload interface{}) (int, error)
@@ -171,6 +268,38 @@ type PgrestAdapter interface {
 	Post(table string, body io.Reader) (*http.Response, error)
 	PostAndReturn(table string, body io.Reader) (*http.Response, error)
 	PostJSON(table string, payload interface{}, target interface{}) (int, error)
+	Put(table string, query *url.Values, body io.Reader) (*http.Response, error)
+	PutJSON(table string, query *url.Values, payload interface{}, target interface{}) (int, error)
+	RPC(fn string, body io.Reader) (*http.Response, error)
+	RPCGet(fn string, args *url.Values) (*http.Response, error)
+	RPCGetJSON(fn string, args *url.Values, target interface{}) (int, error)
+	RPCJSON(fn string, args interface{}, target interface{}) (int, error)
+	Upsert(table string, body io.Reader, resolution Resolution, onConflict ...string) (*http.Response, error)
+	UpsertJSON(table string, payload interface{}, target interface{}, resolution Resolution, onConflict ...string) (int, error)
+}
+
+// PgrestContextAdapter is an interface that describes the context aware methods of the pgrestAgent
+type PgrestContextAdapter interface {
+	DeleteContext(ctx context.Context, table string, query *url.Values) (*http.Response, error)
+	DeleteJSONContext(ctx context.Context, table string, query *url.Values) (int, error)
+	GetContext(ctx context.Context, table string, query *url.Values) (*http.Response, error)
+	GetJSONContext(ctx context.Context, table string, query *url.Values, target interface{}) (int, error)
+	GetResultContext(ctx context.Context, table string, query *url.Values, count Count, target interface{}) (*Result, error)
+	NewRequestContext(ctx context.Context, method, urlStr string, body io.Reader) (*http.Request, error)
+	PatchContext(ctx context.Context, table string, query *url.Values, body io.Reader) (*http.Response, error)
+	PatchJSONContext(ctx context.Context, table string, query *url.Values, payload interface{}) (int, error)
+	PingContext(ctx context.Context) error
+	PostAndReturnContext(ctx context.Context, table string, body io.Reader) (*http.Response, error)
+	PostContext(ctx context.Context, table string, body io.Reader) (*http.Response, error)
+	PostJSONContext(ctx context.Context, table string, payload interface{}, target interface{}) (int, error)
+	PutContext(ctx context.Context, table string, query *url.Values, body io.Reader) (*http.Response, error)
+	PutJSONContext(ctx context.Context, table string, query *url.Values, payload interface{}, target interface{}) (int, error)
+	RPCContext(ctx context.Context, fn string, body io.Reader) (*http.Response, error)
+	RPCGetContext(ctx context.Context, fn string, args *url.Values) (*http.Response, error)
+	RPCGetJSONContext(ctx context.Context, fn string, args *url.Values, target interface{}) (int, error)
+	RPCJSONContext(ctx context.Context, fn string, args interface{}, target interface{}) (int, error)
+	UpsertContext(ctx context.Context, table string, body io.Reader, resolution Resolution, onConflict ...string) (*http.Response, error)
+	UpsertJSONContext(ctx context.Context, table string, payload interface{}, target interface{}, resolution Resolution, onConflict ...string) (int, error)
 }
 
 // JWTGenerator is an interface for generating JSON Web Tokens
@@ -178,14 +307,30 @@ type JWTGenerator func(claims interface{}, secret string) (tokenStr string, err
 
 // Agent encapsulates methods for making HTTP requests to a postgREST service
 type Agent struct {
-	config      *Config
-	httpClient  HTTPClientAdapter
-	generateJWT JWTGenerator
+	config            *Config
+	httpClient        HTTPClientAdapter
+	generateJWT       JWTGenerator
+	masterTokenSource TokenSource
+	slaveTokenSource  TokenSource
+	tokens            *tokenCache
+	scope             *impersonation
+	bearer            BearerTokenSource
+	master            *Endpoint
+	replicas          *replicaPool
+	retryPolicy       *RetryPolicy
+	hedgeDelay        time.Duration
+	flights           *flightGroup
+	cache             *responseCache
 	PgrestAdapter
 }
 
 // NewRequest generates a new request with authorization header for postgrest service
 func (agent *Agent) NewRequest(method, urlStr string, body io.Reader) (*http.Request, error) {
+	return agent.NewRequestContext(context.Background(), method, urlStr, body)
+}
+
+// NewRequestContext is NewRequest with the given context attached to the request
+func (agent *Agent) NewRequestContext(ctx context.Context, method, urlStr string, body io.Reader) (*http.Request, error) {
 	if urlStr == "" {
 		return nil, errMissingRequestURL
 	}
@@ -193,44 +338,76 @@ func (agent *Agent) NewRequest(method, urlStr string, body io.Reader) (*http.Req
 		return nil, errMissingRequestMethod
 	}
 	if method == http.MethodGet {
-		return agent.newReadRequest(method, urlStr)
+		return agent.newReadRequest(ctx, method, urlStr)
 	}
-	return agent.newWriteRequest(method, urlStr, body)
+	return agent.newWriteRequest(ctx, method, urlStr, body)
 }
 
-func (agent *Agent) newReadRequest(method, urlStr string) (*http.Request, error) {
+func (agent *Agent) newReadRequest(ctx context.Context, method, urlStr string) (*http.Request, error) {
 	tokenStr, err := agent.generateReadTokenStr()
 	if err != nil {
 		return nil, err
 	}
-	return newRequest(method, urlStr, tokenStr, nil)
+	return newRequest(ctx, method, urlStr, tokenStr, nil)
 }
 
-func (agent *Agent) newWriteRequest(method, urlStr string, body io.Reader) (*http.Request, error) {
+func (agent *Agent) newWriteRequest(ctx context.Context, method, urlStr string, body io.Reader) (*http.Request, error) {
 	tokenStr, err := agent.generateWriteTokenStr()
 	if err != nil {
 		return nil, err
 	}
-	return newRequest(method, urlStr, tokenStr, body)
+	return newRequest(ctx, method, urlStr, tokenStr, body)
 }
 
 // generateAuthTokenStr generates an authentication string for an Postgrest HTTP authorization header
 func (agent *Agent) generateReadTokenStr() (string, error) {
-	claims := generateClaims(agent.config.SlaveRole, agent.config)
-	tokenStr, err := agent.generateJWT(claims, agent.config.SlaveSecret)
-	return fmt.Sprintf("Bearer %s", tokenStr), err
+	source := agent.slaveTokenSource
+	if source == nil {
+		source = NewSecretTokenSource(agent.config.SlaveSecret, agent.generateJWT)
+	}
+	return agent.generateTokenStr("read", agent.config.SlaveRole, source)
 }
 
 // generateAuthTokenStr generates an authentication string for an Postgrest HTTP authorization header
 func (agent *Agent) generateWriteTokenStr() (string, error) {
-	claims := generateClaims(agent.config.MasterRole, agent.config)
-	tokenStr, err := agent.generateJWT(claims, agent.config.MasterSecret)
-	return fmt.Sprintf("Bearer %s", tokenStr), err
+	source := agent.masterTokenSource
+	if source == nil {
+		source = NewSecretTokenSource(agent.config.MasterSecret, agent.generateJWT)
+	}
+	return agent.generateTokenStr("write", agent.config.MasterRole, source)
+}
+
+// generateTokenStr returns the cached authentication string for role or signs a new one with source.
+// The role and extra claims of an impersonating agent take precedence over the given role
+// and a bearer token source replaces signing altogether.
+func (agent *Agent) generateTokenStr(kind, role string, source TokenSource) (string, error) {
+	if agent.bearer != nil {
+		return agent.bearerTokenStr()
+	}
+	role, extra, scopeKey, err := agent.scopedRole(role)
+	if err != nil {
+		return "", err
+	}
+	sign := func() (string, error) {
+		claims := generateClaims(role, agent.config)
+		claims.Extra = extra
+		tokenStr, err := source.Token(claims)
+		return fmt.Sprintf("Bearer %s", tokenStr), err
+	}
+	if agent.tokens == nil {
+		return sign()
+	}
+	return agent.tokens.get(kind+"\x00"+role+"\x00"+scopeKey, sign)
 }
 
 // Ping sends a request to the postgrest master and slave servers
 // and returns an error if the response status is not bwtween 200 and 299
 func (agent *Agent) Ping() error {
+	return agent.PingContext(context.Background())
+}
+
+// PingContext is Ping with the given context attached to the request
+func (agent *Agent) PingContext(ctx context.Context) error {
 	var urls = []struct {
 		name string
 		url  string
@@ -239,7 +416,7 @@ func (agent *Agent) Ping() error {
 		{"slave", agent.config.SlaveBaseURL},
 	}
 	for _, url := range urls {
-		request, err := agent.sendRequest(http.MethodGet, url.url, nil)
+		request, err := agent.sendRequest(ctx, http.MethodGet, url.url, nil, nil)
 		if err != nil {
 			return fmt.Errorf("%s service error: %v", url.name, err)
 		}
@@ -250,32 +427,83 @@ func (agent *Agent) Ping() error {
 	return nil
 }
 
-// sendRequest sends an HTTP request using the httpClient
-func (agent *Agent) sendRequest(method, urlStr string, body io.Reader) (*http.Response, error) {
-	request, err := agent.NewRequest(method, urlStr, body)
+// sendRequest sends an HTTP request with the given additional headers using the httpClient
+func (agent *Agent) sendRequest(ctx context.Context, method, urlStr string, header http.Header, body io.Reader) (*http.Response, error) {
+	request, err := agent.NewRequestContext(ctx, method, urlStr, body)
 	if err != nil {
 		return nil, err
 	}
+	for key, values := range header {
+		for _, value := range values {
+			request.Header.Add(key, value)
+		}
+	}
 	return agent.httpClient.Do(request)
 }
 
+// send sends an HTTP request for the given path and query parameters to the postgREST service.
+// GET and HEAD requests are balanced between the replicas, other requests are sent to the master.
+// GET responses are served from the response cache when enabled, and invalidated by the writes to their path.
+// Writes are recorded in the Session of ctx, if any. Failed attempts are retried following the RetryPolicy.
+func (agent *Agent) send(ctx context.Context, method, path string, query *url.Values, header http.Header, body io.Reader) (*http.Response, error) {
+	if path == "" {
+		return nil, errMissingURLPath
+	}
+	c := &call{method: method, path: path, query: query, header: header, body: body}
+	if method == http.MethodGet && agent.cache != nil {
+		return agent.cached(ctx, c)
+	}
+	if method == http.MethodGet || method == http.MethodHead {
+		return agent.readThrough(ctx, c)
+	}
+	response, err := agent.retry(ctx, c, func() (*http.Response, error) {
+		return agent.sendTo(ctx, agent.masterEndpoint(), c)
+	})
+	if session := SessionFromContext(ctx); session != nil {
+		session.wrote()
+	}
+	if agent.cache != nil && c.sent() > 0 {
+		agent.cache.invalidate(path)
+	}
+	return response, err
+}
+
+// readThrough sends a read, deduplicated with the identical reads in flight when enabled
+func (agent *Agent) readThrough(ctx context.Context, c *call) (*http.Response, error) {
+	read := func() (*http.Response, error) {
+		return agent.retry(ctx, c, func() (*http.Response, error) {
+			return agent.read(ctx, c)
+		})
+	}
+	if agent.flights != nil && c.method == http.MethodGet {
+		return agent.dedupe(ctx, c, read)
+	}
+	return read()
+}
+
 // Get makes an HTTP GET request to the postgREST slave service specified in the config.
 // To paginate response, set the `offset` and `limit` parameters in the `query` e.g:
 // query.Set("limit", 10)
 // query.Set("offset", 10)
 func (agent *Agent) Get(table string, query *url.Values) (*http.Response, error) {
-	urlStr, err := buildURLStr(agent.config.SlaveBaseURL, table, query)
-	if err != nil {
-		return nil, err
-	}
-	return agent.sendRequest(http.MethodGet, urlStr, nil)
+	return agent.GetContext(context.Background(), table, query)
+}
+
+// GetContext is Get with the given context attached to the request
+func (agent *Agent) GetContext(ctx context.Context, table string, query *url.Values) (*http.Response, error) {
+	return agent.send(ctx, http.MethodGet, table, query, nil, nil)
 }
 
 // GetJSON makes an HTTP GET request to a postgREST service and unmarshals
 // the response into the given target interface
 // Returns error if response status code is not inclusively between 200 and 299
 func (agent *Agent) GetJSON(table string, query *url.Values, target interface{}) (int, error) {
-	response, err := agent.Get(table, query)
+	return agent.GetJSONContext(context.Background(), table, query, target)
+}
+
+// GetJSONContext is GetJSON with the given context attached to the request
+func (agent *Agent) GetJSONContext(ctx context.Context, table string, query *url.Values, target interface{}) (int, error) {
+	response, err := agent.GetContext(ctx, table, query)
 	if err != nil {
 		return 0, err
 	}
@@ -284,30 +512,36 @@ func (agent *Agent) GetJSON(table string, query *url.Values, target interface{})
 
 // Post makes an HTTP POST request to the postgREST master service specified in the config.
 func (agent *Agent) Post(table string, body io.Reader) (*http.Response, error) {
-	urlStr, err := buildURLStr(agent.config.MasterBaseURL, table, nil)
-	if err != nil {
-		return nil, err
-	}
-	return agent.sendRequest(http.MethodPost, urlStr, body)
+	return agent.PostContext(context.Background(), table, body)
+}
+
+// PostContext is Post with the given context attached to the request
+func (agent *Agent) PostContext(ctx context.Context, table string, body io.Reader) (*http.Response, error) {
+	return agent.send(ctx, http.MethodPost, table, nil, nil, body)
 }
 
 // PostJSON makes an HTTP POST request to a postgREST service and unmarshals
 // the response into the given target interface
 // Returns error if the response status code is not inclusively between 200 and 299
 func (agent *Agent) PostJSON(table string, payload interface{}, target interface{}) (int, error) {
+	return agent.PostJSONContext(context.Background(), table, payload, target)
+}
+
+// PostJSONContext is PostJSON with the given context attached to the request
+func (agent *Agent) PostJSONContext(ctx context.Context, table string, payload interface{}, target interface{}) (int, error) {
 	var response *http.Response
 	body, err := jsonEncode(payload)
 	if err != nil {
 		return 0, err
 	}
 	if target == nil {
-		response, err = agent.Post(table, body)
+		response, err = agent.PostContext(ctx, table, body)
 		if err != nil {
 			return 0, err
 		}
 		return unmarshalResponse(response, nil)
 	}
-	response, err = agent.PostAndReturn(table, body)
+	response, err = agent.PostAndReturnContext(ctx, table, body)
 	if err != nil {
 		return 0, err
 	}
@@ -317,35 +551,38 @@ func (agent *Agent) PostJSON(table string, payload interface{}, target interface
 // PostAndReturn makes an HTTP POST request to the postgREST master service specified in the config
 // and returns the http.Response with a representation of the posted object.
 func (agent *Agent) PostAndReturn(table string, body io.Reader) (*http.Response, error) {
-	urlStr, err := buildURLStr(agent.config.MasterBaseURL, table, nil)
-	if err != nil {
-		return nil, err
-	}
-	request, err := agent.NewRequest(http.MethodPost, urlStr, body)
-	if err != nil {
-		return nil, err
-	}
-	request.Header.Add("Prefer", "return=representation")
-	return agent.httpClient.Do(request)
+	return agent.PostAndReturnContext(context.Background(), table, body)
+}
+
+// PostAndReturnContext is PostAndReturn with the given context attached to the request
+func (agent *Agent) PostAndReturnContext(ctx context.Context, table string, body io.Reader) (*http.Response, error) {
+	header := http.Header{"Prefer": {"return=representation"}}
+	return agent.send(ctx, http.MethodPost, table, nil, header, body)
 }
 
 // Patch makes an HTTP PATCH request to a postgREST service specified in the config
 func (agent *Agent) Patch(table string, query *url.Values, body io.Reader) (*http.Response, error) {
-	urlStr, err := buildURLStr(agent.config.MasterBaseURL, table, query)
-	if err != nil {
-		return nil, err
-	}
-	return agent.sendRequest(http.MethodPatch, urlStr, body)
+	return agent.PatchContext(context.Background(), table, query, body)
+}
+
+// PatchContext is Patch with the given context attached to the request
+func (agent *Agent) PatchContext(ctx context.Context, table string, query *url.Values, body io.Reader) (*http.Response, error) {
+	return agent.send(ctx, http.MethodPatch, table, query, nil, body)
 }
 
 // PatchJSON makes an HTTP PATCH request to a postgREST service
 // Returns an error if the response status code is not inclusively between 200 and 299
 func (agent *Agent) PatchJSON(table string, query *url.Values, payload interface{}) (int, error) {
+	return agent.PatchJSONContext(context.Background(), table, query, payload)
+}
+
+// PatchJSONContext is PatchJSON with the given context attached to the request
+func (agent *Agent) PatchJSONContext(ctx context.Context, table string, query *url.Values, payload interface{}) (int, error) {
 	body, err := jsonEncode(payload)
 	if err != nil {
 		return 0, err
 	}
-	response, err := agent.Patch(table, query, body)
+	response, err := agent.PatchContext(ctx, table, query, body)
 	if err != nil {
 		return 0, err
 	}
@@ -354,24 +591,44 @@ func (agent *Agent) PatchJSON(table string, query *url.Values, payload interface
 
 // Delete makes an HTTP DELETE request to the postgREST master service specified in the config
 func (agent *Agent) Delete(table string, query *url.Values) (*http.Response, error) {
-	urlStr, err := buildURLStr(agent.config.MasterBaseURL, table, query)
-	if err != nil {
-		return nil, err
-	}
-	return agent.sendRequest(http.MethodDelete, urlStr, nil)
+	return agent.DeleteContext(context.Background(), table, query)
+}
+
+// DeleteContext is Delete with the given context attached to the request
+func (agent *Agent) DeleteContext(ctx context.Context, table string, query *url.Values) (*http.Response, error) {
+	return agent.send(ctx, http.MethodDelete, table, query, nil, nil)
 }
 
 // DeleteJSON makes an HTTP DELETE request to a postgREST service
 // Returns an error if the response status code is not inclusively between 200 and 299
 func (agent *Agent) DeleteJSON(table string, query *url.Values) (int, error) {
-	response, err := agent.Delete(table, query)
+	return agent.DeleteJSONContext(context.Background(), table, query)
+}
+
+// DeleteJSONContext is DeleteJSON with the given context attached to the request
+func (agent *Agent) DeleteJSONContext(ctx context.Context, table string, query *url.Values) (int, error) {
+	response, err := agent.DeleteContext(ctx, table, query)
 	if err != nil {
 		return 0, err
 	}
 	return unmarshalResponse(response, nil)
 }
 
+// builtinJWTGenerator returns the built-in JWTGenerator for the given algorithm, HS256 by default
+func builtinJWTGenerator(algorithm string) (JWTGenerator, error) {
+	if algorithm == "" {
+		algorithm = "HS256"
+	}
+	jwtGenerator, ok := jwtGenerators[algorithm]
+	if !ok {
+		return nil, errUnsupportedAlgorithm
+	}
+	return jwtGenerator, nil
+}
+
 // NewAgent returns a new instance of Agent
+// If jwtGenerator is nil the built-in generator for config.Algorithm (HS256 by default) is used
+// and config.MasterSecret and config.SlaveSecret contain the HMAC secrets or PEM encoded private keys.
 func NewAgent(config *Config, httpClient HTTPClientAdapter, jwtGenerator JWTGenerator) (*Agent, error) {
 	if config == nil {
 		return nil, errMissingConfigParams
@@ -379,12 +636,64 @@ func NewAgent(config *Config, httpClient HTTPClientAdapter, jwtGenerator JWTGene
 	if httpClient == nil {
 		return nil, errMissingHTTPClient
 	}
+	err := validateConfig(config)
+	if err != nil {
+		return nil, err
+	}
 	if jwtGenerator == nil {
-		return nil, errMissingJWTGenerator
+		jwtGenerator, err = builtinJWTGenerator(config.Algorithm)
+		if err != nil {
+			return nil, err
+		}
 	}
-	err := validateConfig(config)
+	agent, err := newAgent(config, httpClient, NewSecretTokenSource(config.MasterSecret, jwtGenerator),
+		NewSecretTokenSource(config.SlaveSecret, jwtGenerator))
+	if err != nil {
+		return nil, err
+	}
+	agent.generateJWT = jwtGenerator
+	return agent, nil
+}
+
+// NewAgentWithTokenSources returns a new postgrest agent signing write requests with master and
+// read requests with slave, e.g: a KeyTokenSource or a JWKSTokenSource.
+// MasterSecret and SlaveSecret are not required in the config.
+func NewAgentWithTokenSources(config *Config, httpClient HTTPClientAdapter, master, slave TokenSource) (*Agent, error) {
+	if config == nil {
+		return nil, errMissingConfigParams
+	}
+	if httpClient == nil {
+		return nil, errMissingHTTPClient
+	}
+	if master == nil || slave == nil {
+		return nil, errMissingTokenSource
+	}
+	if err := validateConfig(config, "MasterSecret", "SlaveSecret"); err != nil {
+		return nil, err
+	}
+	return newAgent(config, httpClient, master, slave)
+}
+
+// newAgent returns a new postgrest agent for a validated config
+func newAgent(config *Config, httpClient HTTPClientAdapter, master, slave TokenSource) (*Agent, error) {
+	lifetime, err := tokenLifetime(config)
 	if err != nil {
 		return nil, err
 	}
-	return &Agent{config: config, httpClient: httpClient, generateJWT: jwtGenerator}, nil
+	agent := &Agent{
+		config:            config,
+		httpClient:        httpClient,
+		masterTokenSource: master,
+		slaveTokenSource:  slave,
+		tokens:            newTokenCache(lifetime),
+		master:            newEndpoint(config.MasterBaseURL, 1, true, config),
+		replicas:          newReplicaPool(config),
+		retryPolicy:       newRetryPolicy(config.RetryPolicy),
+		hedgeDelay:        config.HedgeDelay,
+		cache:             newResponseCache(config.ResponseCache),
+	}
+	if config.Deduplicate {
+		agent.flights = newFlightGroup()
+	}
+	return agent, nil
 }
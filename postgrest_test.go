@@ -2,6 +2,7 @@ package postgrest
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -89,9 +90,17 @@ func TestNewAgent(t *testing.T) {
 		t.Errorf("NewAgent returned unexpected error:\nExpected: %v\nGot: %v", errMissingHTTPClient, err)
 	}
 
-	_, err = NewAgent(testConfig, &http.Client{}, nil)
-	if err == nil || err.Error() != errMissingJWTGenerator.Error() {
-		t.Errorf("NewAgent returned unexpected error:\nExpected: %v\nGot: %v", errMissingJWTGenerator, err)
+	agent, err := NewAgent(testConfig, &http.Client{}, nil)
+	if err != nil || agent.generateJWT == nil {
+		t.Errorf("NewAgent returned unexpected error: %v", err)
+	}
+
+	unsupportedConfig := &Config{}
+	*unsupportedConfig = *testConfig
+	unsupportedConfig.Algorithm = "none"
+	_, err = NewAgent(unsupportedConfig, &http.Client{}, nil)
+	if err == nil || err.Error() != errUnsupportedAlgorithm.Error() {
+		t.Errorf("NewAgent returned unexpected error:\nExpected: %v\nGot: %v", errUnsupportedAlgorithm, err)
 	}
 
 	_, err = NewAgent(&Config{}, &http.Client{}, func(_ interface{}, _ string) (string, error) { return "", nil })
@@ -99,7 +108,16 @@ func TestNewAgent(t *testing.T) {
 		t.Errorf("NewAgent returned unexpected error:\nExpected: %v...\nGot: %v", errMissingConfigParams, err)
 	}
 
-	agent, err := NewAgent(testConfig, &http.Client{}, func(_ interface{}, _ string) (string, error) { return "", nil })
+	replicaConfig := &Config{}
+	*replicaConfig = *testConfig
+	replicaConfig.Replicas = []Replica{{BaseURL: server.URL}, {BaseURL: ""}, {BaseURL: "replica:3000"}, {BaseURL: "http://%zz"}}
+	_, err = NewAgent(replicaConfig, &http.Client{}, nil)
+	expected := "postgrest error: invalid config parameters: \n- Replicas[1].BaseURL\n- Replicas[2].BaseURL\n- Replicas[3].BaseURL"
+	if err == nil || err.Error() != expected {
+		t.Errorf("NewAgent returned unexpected error:\nExpected: %v\nGot: %v", expected, err)
+	}
+
+	agent, err = NewAgent(testConfig, &http.Client{}, func(_ interface{}, _ string) (string, error) { return "", nil })
 	if err != nil {
 		fmt.Println(testConfig)
 		t.Errorf("NewAgent returned unexpected error: %v", err)
@@ -555,6 +573,57 @@ func TestNewRequest(t *testing.T) {
 	}
 }
 
+func TestContext(t *testing.T) {
+	t.Parallel()
+
+	testConfig := &Config{
+		Issuer:        "test",
+		MasterBaseURL: server.URL,
+		MasterRole:    "masterRole",
+		MasterSecret:  "masterSecret",
+		SlaveBaseURL:  server.URL,
+		SlaveRole:     "slaveRole",
+		SlaveSecret:   "slaveSecret",
+		Timeout:       5,
+	}
+	testAgent := &Agent{
+		config:      testConfig,
+		httpClient:  &http.Client{},
+		generateJWT: func(_ interface{}, _ string) (string, error) { return "secret", nil },
+	}
+
+	type contextKey string
+	ctx := context.WithValue(context.Background(), contextKey("key"), "value")
+	request, err := testAgent.NewRequestContext(ctx, http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Errorf("NewRequestContext returned unexpected error: %v", err)
+	}
+	if request.Context() != ctx {
+		t.Errorf("NewRequestContext returned unexpected context:\nExpected: %v\nGot: %v", ctx, request.Context())
+	}
+
+	status, err := testAgent.GetJSONContext(ctx, "test_table", nil, &object{})
+	if err != nil || status != http.StatusOK {
+		t.Errorf("GetJSONContext returned unexpected results:\nExpected: %d\nGot: %d %v", http.StatusOK, status, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	expectedError := context.Canceled
+	if _, err := testAgent.GetContext(ctx, "test_table", nil); err == nil || !strings.Contains(err.Error(), expectedError.Error()) {
+		t.Errorf("GetContext returned unexpected error:\nExpected: %v\nGot: %v", expectedError, err)
+	}
+	if _, err := testAgent.PostJSONContext(ctx, "test_table", testObject, nil); err == nil || !strings.Contains(err.Error(), expectedError.Error()) {
+		t.Errorf("PostJSONContext returned unexpected error:\nExpected: %v\nGot: %v", expectedError, err)
+	}
+	if _, err := testAgent.From("test_table").WithContext(ctx).DeleteJSON(); err == nil || !strings.Contains(err.Error(), expectedError.Error()) {
+		t.Errorf("DeleteJSON returned unexpected error:\nExpected: %v\nGot: %v", expectedError, err)
+	}
+	if err := testAgent.PingContext(ctx); err == nil || !strings.Contains(err.Error(), expectedError.Error()) {
+		t.Errorf("PingContext returned unexpected error:\nExpected: %v\nGot: %v", expectedError, err)
+	}
+}
+
 func TestClaims(t *testing.T) {
 	t.Parallel()
 
@@ -0,0 +1,410 @@
+package postgrest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Direction specifies the sort order of a column in a Query
+type Direction string
+
+// sort directions supported by postgREST
+const (
+	Asc            Direction = "asc"
+	Desc           Direction = "desc"
+	AscNullsFirst  Direction = "asc.nullsfirst"
+	AscNullsLast   Direction = "asc.nullslast"
+	DescNullsFirst Direction = "desc.nullsfirst"
+	DescNullsLast  Direction = "desc.nullslast"
+)
+
+// reservedChars are characters that must be quoted when they appear in postgREST list values
+const reservedChars = ",.:()\"\\"
+
+// Query builds the query parameters of a postgREST request against a single table
+// e.g: agent.From("users").Select("id", "email").Eq("last_name", "TEST").Order("id", Desc).Limit(10)
+// A Query is not safe for concurrent use.
+type Query struct {
+	agent   *Agent
+	ctx     context.Context
+	table   string
+	selects []string
+	embeds  []*Embed
+	filters []Filter
+	order   []string
+	limit   int
+	offset  int
+	params  url.Values
+	header  http.Header
+	count   Count
+	err     error
+}
+
+// From returns a new Query for the given table
+func (agent *Agent) From(table string) *Query {
+	return &Query{agent: agent, ctx: context.Background(), table: table, limit: -1, offset: -1, params: url.Values{}, header: http.Header{}}
+}
+
+// WithContext attaches ctx to the requests made by the query
+func (q *Query) WithContext(ctx context.Context) *Query {
+	q.ctx = ctx
+	return q
+}
+
+// Select adds the given columns to the `select` parameter of the query
+func (q *Query) Select(columns ...string) *Query {
+	q.selects = append(q.selects, columns...)
+	return q
+}
+
+// Embed adds the given related resources to the `select` parameter of the query
+func (q *Query) Embed(embeds ...*Embed) *Query {
+	q.embeds = append(q.embeds, embeds...)
+	return q
+}
+
+// Where adds the given filters to the query
+func (q *Query) Where(filters ...Filter) *Query {
+	q.filters = append(q.filters, filters...)
+	return q
+}
+
+// WhereEmbedded adds the given filters to the query, scoped to the embedded resource
+// e.g: WhereEmbedded("orders", Or(Gt("total", 100), Eq("status", "open"))) produces orders.or=(...)
+func (q *Query) WhereEmbedded(resource string, filters ...Filter) *Query {
+	for _, filter := range filters {
+		q.filters = append(q.filters, scopedFilter{resource, filter})
+	}
+	return q
+}
+
+// Or adds a filter matching rows that satisfy any of the given filters
+func (q *Query) Or(filters ...Filter) *Query {
+	return q.Where(Or(filters...))
+}
+
+// Not adds the negation of the given filter to the query
+func (q *Query) Not(filter Filter) *Query {
+	return q.Where(Not(filter))
+}
+
+// Filter adds a raw `column=operator.value` filter to the query.
+// The value is used verbatim and is not quoted or escaped.
+func (q *Query) Filter(column, operator, value string) *Query {
+	return q.Where(Raw(column, operator, value))
+}
+
+// Eq filters rows where column is equal to value
+func (q *Query) Eq(column string, value interface{}) *Query {
+	return q.Where(Eq(column, value))
+}
+
+// Neq filters rows where column is not equal to value
+func (q *Query) Neq(column string, value interface{}) *Query {
+	return q.Where(Neq(column, value))
+}
+
+// Gt filters rows where column is greater than value
+func (q *Query) Gt(column string, value interface{}) *Query {
+	return q.Where(Gt(column, value))
+}
+
+// Gte filters rows where column is greater than or equal to value
+func (q *Query) Gte(column string, value interface{}) *Query {
+	return q.Where(Gte(column, value))
+}
+
+// Lt filters rows where column is less than value
+func (q *Query) Lt(column string, value interface{}) *Query {
+	return q.Where(Lt(column, value))
+}
+
+// Lte filters rows where column is less than or equal to value
+func (q *Query) Lte(column string, value interface{}) *Query {
+	return q.Where(Lte(column, value))
+}
+
+// Like filters rows where column matches the LIKE pattern. `*` may be used in place of `%`
+func (q *Query) Like(column, pattern string) *Query {
+	return q.Where(Like(column, pattern))
+}
+
+// Ilike filters rows where column matches the case insensitive ILIKE pattern. `*` may be used in place of `%`
+func (q *Query) Ilike(column, pattern string) *Query {
+	return q.Where(Ilike(column, pattern))
+}
+
+// Is filters rows where column IS value. value must be nil, a bool or "unknown"
+func (q *Query) Is(column string, value interface{}) *Query {
+	return q.Where(Is(column, value))
+}
+
+// In filters rows where column is one of the given values.
+// A single slice argument is expanded into its elements.
+func (q *Query) In(column string, values ...interface{}) *Query {
+	return q.Where(In(column, values...))
+}
+
+// Contains filters rows where column contains value (cs).
+// Slices are rendered as array literals, maps and structs as JSON and strings (e.g. ranges) verbatim.
+func (q *Query) Contains(column string, value interface{}) *Query {
+	return q.Where(Contains(column, value))
+}
+
+// ContainedBy filters rows where column is contained by value (cd)
+func (q *Query) ContainedBy(column string, value interface{}) *Query {
+	return q.Where(ContainedBy(column, value))
+}
+
+// Overlaps filters rows where column has elements in common with value (ov)
+func (q *Query) Overlaps(column string, value interface{}) *Query {
+	return q.Where(Overlaps(column, value))
+}
+
+// Fts filters rows where the tsvector column matches the to_tsquery of query.
+// config is an optional text search configuration e.g. "english"
+func (q *Query) Fts(column, query, config string) *Query {
+	return q.Where(Fts(column, query, config))
+}
+
+// Plfts filters rows where the tsvector column matches the plainto_tsquery of query
+func (q *Query) Plfts(column, query, config string) *Query {
+	return q.Where(Plfts(column, query, config))
+}
+
+// Phfts filters rows where the tsvector column matches the phraseto_tsquery of query
+func (q *Query) Phfts(column, query, config string) *Query {
+	return q.Where(Phfts(column, query, config))
+}
+
+// Wfts filters rows where the tsvector column matches the websearch_to_tsquery of query
+func (q *Query) Wfts(column, query, config string) *Query {
+	return q.Where(Wfts(column, query, config))
+}
+
+// Order adds column to the `order` parameter of the query
+func (q *Query) Order(column string, direction Direction) *Query {
+	q.order = append(q.order, orderItem(column, direction))
+	return q
+}
+
+// Limit limits the number of rows returned
+func (q *Query) Limit(limit int) *Query {
+	q.limit = limit
+	return q
+}
+
+// Offset skips the given number of rows
+func (q *Query) Offset(offset int) *Query {
+	q.offset = offset
+	return q
+}
+
+// Single requests a single JSON object instead of an array. postgREST responds with an error
+// unless exactly one row is returned.
+func (q *Query) Single() *Query {
+	q.header.Set("Accept", "application/vnd.pgrst.object+json")
+	return q
+}
+
+// Values compiles the query into the query parameters accepted by Agent.Get, Agent.Patch and Agent.Delete
+func (q *Query) Values() *url.Values {
+	values := &url.Values{}
+	for key, params := range q.params {
+		(*values)[key] = append([]string{}, params...)
+	}
+	if len(q.selects) > 0 || len(q.embeds) > 0 {
+		values.Set("select", selectList(q.selects, q.embeds))
+	}
+	for _, filter := range q.filters {
+		values.Add(filter.key(), filter.value())
+	}
+	for _, embed := range q.embeds {
+		embed.addParams(values, "")
+	}
+	if len(q.order) > 0 {
+		values.Set("order", strings.Join(q.order, ","))
+	}
+	if q.limit >= 0 {
+		values.Set("limit", strconv.Itoa(q.limit))
+	}
+	if q.offset >= 0 {
+		values.Set("offset", strconv.Itoa(q.offset))
+	}
+	return values
+}
+
+// Get makes an HTTP GET request for the query to the postgREST slave service
+func (q *Query) Get() (*http.Response, error) {
+	if q.err != nil {
+		return nil, q.err
+	}
+	return q.agent.send(q.ctx, http.MethodGet, q.table, q.Values(), q.requestHeader(), nil)
+}
+
+// GetJSON makes an HTTP GET request for the query and unmarshals the response into the given target interface.
+// If no columns were selected the select list is derived from the struct tags of target (see SelectFor).
+func (q *Query) GetJSON(target interface{}) (int, error) {
+	response, err := q.get(target)
+	if err != nil {
+		return 0, err
+	}
+	return unmarshalResponse(response, target)
+}
+
+// get makes an HTTP GET request for the query selecting the columns of target if no columns were selected
+func (q *Query) get(target interface{}) (*http.Response, error) {
+	if q.err != nil {
+		return nil, q.err
+	}
+	values := q.Values()
+	if values.Get("select") == "" {
+		if selectStr, err := SelectFor(target); err == nil && selectStr != "" {
+			values.Set("select", selectStr)
+		}
+	}
+	return q.agent.send(q.ctx, http.MethodGet, q.table, values, q.requestHeader(), nil)
+}
+
+// Patch makes an HTTP PATCH request for the rows matched by the query to the postgREST master service
+func (q *Query) Patch(body io.Reader) (*http.Response, error) {
+	if q.err != nil {
+		return nil, q.err
+	}
+	return q.agent.send(q.ctx, http.MethodPatch, q.table, q.Values(), q.requestHeader(), body)
+}
+
+// PatchJSON makes an HTTP PATCH request with the JSON encoded payload for the rows matched by the query
+func (q *Query) PatchJSON(payload interface{}) (int, error) {
+	body, err := jsonEncode(payload)
+	if err != nil {
+		return 0, err
+	}
+	response, err := q.Patch(body)
+	if err != nil {
+		return 0, err
+	}
+	return unmarshalResponse(response, nil)
+}
+
+// Delete makes an HTTP DELETE request for the rows matched by the query to the postgREST master service
+func (q *Query) Delete() (*http.Response, error) {
+	if q.err != nil {
+		return nil, q.err
+	}
+	return q.agent.send(q.ctx, http.MethodDelete, q.table, q.Values(), q.requestHeader(), nil)
+}
+
+// DeleteJSON makes an HTTP DELETE request for the rows matched by the query
+func (q *Query) DeleteJSON() (int, error) {
+	response, err := q.Delete()
+	if err != nil {
+		return 0, err
+	}
+	return unmarshalResponse(response, nil)
+}
+
+// orderItem renders column and direction as an item of the `order` parameter e.g: id.desc
+func orderItem(column string, direction Direction) string {
+	if direction == "" {
+		return column
+	}
+	return column + "." + string(direction)
+}
+
+// formatValue converts a filter value into its postgREST representation
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// quoteValue wraps value in double quotes if it contains characters reserved by postgREST
+func quoteValue(value string) string {
+	if value != "" && !strings.ContainsAny(value, reservedChars) && strings.TrimSpace(value) == value {
+		return value
+	}
+	return quote(value)
+}
+
+// quote wraps value in double quotes, escaping backslashes and double quotes
+func quote(value string) string {
+	value = strings.Replace(value, `\`, `\\`, -1)
+	value = strings.Replace(value, `"`, `\"`, -1)
+	return `"` + value + `"`
+}
+
+// expand returns the elements of values, expanding a single slice argument
+func expand(values []interface{}) []interface{} {
+	if len(values) != 1 {
+		return values
+	}
+	if _, ok := values[0].([]byte); ok {
+		return values
+	}
+	rv := reflect.ValueOf(values[0])
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return values
+	}
+	expanded := make([]interface{}, rv.Len())
+	for i := range expanded {
+		expanded[i] = rv.Index(i).Interface()
+	}
+	return expanded
+}
+
+// formatList renders values as a postgREST list e.g: (1,2,"a,b")
+func formatList(values []interface{}) string {
+	items := make([]string, 0, len(values))
+	for _, value := range expand(values) {
+		items = append(items, quoteValue(formatValue(value)))
+	}
+	return "(" + strings.Join(items, ",") + ")"
+}
+
+// formatCollection renders slices as postgreSQL array literals and maps or structs as JSON
+func formatCollection(value interface{}) string {
+	switch value.(type) {
+	case nil, string, []byte, time.Time:
+		return formatValue(value)
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		items := make([]string, rv.Len())
+		for i := range items {
+			items[i] = quoteArrayElement(formatValue(rv.Index(i).Interface()))
+		}
+		return "{" + strings.Join(items, ",") + "}"
+	case reflect.Map, reflect.Struct:
+		if b, err := json.Marshal(value); err == nil {
+			return string(b)
+		}
+	}
+	return formatValue(value)
+}
+
+// quoteArrayElement quotes an element of a postgreSQL array literal when necessary
+func quoteArrayElement(value string) string {
+	if value == "" || strings.EqualFold(value, "null") || strings.ContainsAny(value, "{},\"\\ \t\n\r") {
+		return quote(value)
+	}
+	return value
+}
@@ -0,0 +1,151 @@
+package postgrest
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newTestAgent(baseURL string) *Agent {
+	return &Agent{
+		config: &Config{
+			Issuer:        "test",
+			MasterBaseURL: baseURL,
+			MasterRole:    "masterRole",
+			MasterSecret:  "masterSecret",
+			SlaveBaseURL:  baseURL,
+			SlaveRole:     "slaveRole",
+			SlaveSecret:   "slaveSecret",
+			Timeout:       5,
+		},
+		httpClient:  &http.Client{},
+		generateJWT: func(_ interface{}, _ string) (string, error) { return "secret", nil },
+	}
+}
+
+func TestQueryValues(t *testing.T) {
+	t.Parallel()
+
+	testAgent := newTestAgent(server.URL)
+	values := testAgent.From("users").
+		Select("id", "email").
+		Eq("last_name", "TEST").
+		Gte("age", 18).
+		Lte("age", 30).
+		Is("deleted_at", nil).
+		In("status", "active", "on,hold").
+		Order("id", Desc).
+		Order("email", "").
+		Limit(10).
+		Offset(20).
+		Values()
+
+	expected := &url.Values{
+		"select":     {"id,email"},
+		"last_name":  {"eq.TEST"},
+		"age":        {"gte.18", "lte.30"},
+		"deleted_at": {"is.null"},
+		"status":     {`in.(active,"on,hold")`},
+		"order":      {"id.desc,email"},
+		"limit":      {"10"},
+		"offset":     {"20"},
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Values returned unexpected results:\nExpected: %v\nGot: %v", expected, values)
+	}
+
+	urlStr, _ := buildURLStr(server.URL, "users", values)
+	expectedURLStr, _ := buildURLStr(server.URL, "users", expected)
+	if urlStr != expectedURLStr {
+		t.Errorf("buildURLStr returned unexpected url:\nExpected: %s\nGot: %s", expectedURLStr, urlStr)
+	}
+
+	values = testAgent.From("users").Values()
+	if len(*values) != 0 {
+		t.Errorf("Values returned unexpected results:\nExpected: %v\nGot: %v", url.Values{}, values)
+	}
+}
+
+func TestQueryOperators(t *testing.T) {
+	t.Parallel()
+
+	testAgent := newTestAgent(server.URL)
+	date := time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC)
+	var tests = []struct {
+		query    *Query
+		column   string
+		expected string
+	}{
+		{testAgent.From("t").Neq("a", 1), "a", "neq.1"},
+		{testAgent.From("t").Gt("a", 1.5), "a", "gt.1.5"},
+		{testAgent.From("t").Lt("a", date), "a", "lt.2017-01-02T03:04:05Z"},
+		{testAgent.From("t").Like("a", "*test*"), "a", "like.*test*"},
+		{testAgent.From("t").Ilike("a", "*test*"), "a", "ilike.*test*"},
+		{testAgent.From("t").Is("a", true), "a", "is.true"},
+		{testAgent.From("t").In("a", []int{1, 2, 3}), "a", "in.(1,2,3)"},
+		{testAgent.From("t").In("a", `say "hi"`, `back\slash`, ""), "a", `in.("say \"hi\"","back\\slash","")`},
+		{testAgent.From("t").Contains("a", []string{"x", "y z"}), "a", `cs.{x,"y z"}`},
+		{testAgent.From("t").ContainedBy("a", "[1,5)"), "a", "cd.[1,5)"},
+		{testAgent.From("t").Contains("a", map[string]int{"b": 1}), "a", `cs.{"b":1}`},
+		{testAgent.From("t").Overlaps("a", []string{"NULL", ""}), "a", `ov.{"NULL",""}`},
+		{testAgent.From("t").Fts("a", "cat & dog", ""), "a", "fts.cat & dog"},
+		{testAgent.From("t").Plfts("a", "cat dog", "english"), "a", "plfts(english).cat dog"},
+		{testAgent.From("t").Phfts("a", "cat dog", ""), "a", "phfts.cat dog"},
+		{testAgent.From("t").Wfts("a", "cat or dog", "simple"), "a", "wfts(simple).cat or dog"},
+		{testAgent.From("t").Filter("a", "eq", "raw"), "a", "eq.raw"},
+	}
+	for _, test := range tests {
+		if got := test.query.Values().Get(test.column); got != test.expected {
+			t.Errorf("Query returned unexpected filter:\nExpected: %s\nGot: %s", test.expected, got)
+		}
+	}
+}
+
+func TestQueryRequests(t *testing.T) {
+	t.Parallel()
+
+	testAgent := newTestAgent(server.URL)
+
+	obj := &object{}
+	status, err := testAgent.From("test_table").Eq("id", testObject.ID).GetJSON(obj)
+	if err != nil {
+		t.Errorf("GetJSON returned unexpected error: %v", err)
+	}
+	if status != http.StatusOK {
+		t.Errorf("GetJSON returned unexpected status code:\nExpected: %d\nGot: %d", http.StatusOK, status)
+	}
+	if !reflect.DeepEqual(obj, testObject) {
+		t.Errorf("GetJSON returned unexpected results:\nExpected: %v\nGot: %v", testObject, obj)
+	}
+
+	response, err := testAgent.From("test_table").Eq("id", testObject.ID).Get()
+	if err != nil {
+		t.Errorf("Get returned unexpected error: %v", err)
+	}
+	if response.Request.URL.RawQuery != "id=eq.12345678900" {
+		t.Errorf("Get requested unexpected url:\nExpected: %s\nGot: %s", "id=eq.12345678900", response.Request.URL.RawQuery)
+	}
+
+	status, err = testAgent.From("test_table").Eq("id", testObject.ID).PatchJSON(testObject)
+	if err != nil {
+		t.Errorf("PatchJSON returned unexpected error: %v", err)
+	}
+	if status != http.StatusNoContent {
+		t.Errorf("PatchJSON returned unexpected status code:\nExpected: %d\nGot: %d", http.StatusNoContent, status)
+	}
+
+	status, err = testAgent.From("test_table").Eq("id", testObject.ID).DeleteJSON()
+	if err != nil {
+		t.Errorf("DeleteJSON returned unexpected error: %v", err)
+	}
+	if status != http.StatusOK {
+		t.Errorf("DeleteJSON returned unexpected status code:\nExpected: %d\nGot: %d", http.StatusOK, status)
+	}
+
+	_, err = testAgent.From("").Get()
+	if err == nil || err.Error() != errMissingURLPath.Error() {
+		t.Errorf("Get returned unexpected error:\nExpected: %v\nGot: %v", errMissingURLPath, err)
+	}
+}
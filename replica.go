@@ -0,0 +1,434 @@
+package postgrest
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// default endpoint health tracking settings
+const (
+	defaultEjectionThreshold = 3
+	defaultEjectionTimeout   = 30 * time.Second
+)
+
+// Replica is a postgREST read replica served along with SlaveBaseURL
+type Replica struct {
+	BaseURL string `yaml:"base_url" required:"true"`
+	Weight  int    `yaml:"weight,omitempty"`
+}
+
+// Endpoint is a postgREST service requests are routed to
+type Endpoint struct {
+	outstanding     int64
+	baseURL         string
+	weight          int
+	master          bool
+	threshold       int
+	ejection        time.Duration
+	mu              sync.Mutex
+	failures        int
+	ejectedUntil    time.Time
+	breaker         *BreakerSettings
+	state           BreakerState
+	breakerFailures int
+	openedAt        time.Time
+	trials          int
+	successes       int
+	limiter         *limiter
+}
+
+// newEndpoint returns an Endpoint ejected for the config's ejection timeout after its ejection threshold
+// of consecutive failures and guarded by the config's circuit breaker, if any.
+// The master endpoint is limited by the config's master limits.
+func newEndpoint(baseURL string, weight int, master bool, config *Config) *Endpoint {
+	if weight <= 0 {
+		weight = 1
+	}
+	threshold := config.EjectionThreshold
+	if threshold <= 0 {
+		threshold = defaultEjectionThreshold
+	}
+	ejection := config.EjectionTimeout
+	if ejection <= 0 {
+		ejection = defaultEjectionTimeout
+	}
+	endpoint := &Endpoint{
+		baseURL:   baseURL,
+		weight:    weight,
+		master:    master,
+		threshold: threshold,
+		ejection:  ejection,
+		breaker:   newBreakerSettings(config.CircuitBreaker),
+	}
+	if master {
+		endpoint.limiter = newLimiter(config.MasterLimits)
+	}
+	return endpoint
+}
+
+// BaseURL returns the base url of the endpoint
+func (e *Endpoint) BaseURL() string {
+	return e.baseURL
+}
+
+// Weight returns the weight of the endpoint used by weighted balancers
+func (e *Endpoint) Weight() int {
+	return e.weight
+}
+
+// Outstanding returns the number of requests sent to the endpoint whose response body is not closed yet
+func (e *Endpoint) Outstanding() int {
+	return int(atomic.LoadInt64(&e.outstanding))
+}
+
+// healthy returns true if the endpoint is neither ejected nor short-circuited at the given time by its open
+// breaker or by its half-open breaker whose trial requests are all in flight
+func (e *Endpoint) healthy(now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.breaker != nil && e.state == BreakerOpen && now.Before(e.openedAt.Add(e.breaker.CoolDown)) {
+		return false
+	}
+	if e.breaker != nil && e.state == BreakerHalfOpen && e.trials >= e.breaker.HalfOpenRequests {
+		return false
+	}
+	return !now.Before(e.ejectedUntil)
+}
+
+// report records the outcome of a request, ejecting the endpoint after too many consecutive failures
+func (e *Endpoint) report(failed bool) {
+	e.mu.Lock()
+	notify := e.recordBreaker(failed)
+	if !failed {
+		e.failures = 0
+	} else if e.failures++; e.failures >= e.threshold {
+		e.failures = 0
+		e.ejectedUntil = time.Now().Add(e.ejection)
+	}
+	e.mu.Unlock()
+	notify()
+}
+
+// acquire counts a request as outstanding and returns the function releasing it
+func (e *Endpoint) acquire() func() {
+	atomic.AddInt64(&e.outstanding, 1)
+	var once sync.Once
+	return func() {
+		once.Do(func() { atomic.AddInt64(&e.outstanding, -1) })
+	}
+}
+
+// Balancer picks the endpoint of a read request among the healthy replica endpoints
+type Balancer interface {
+	Pick(endpoints []*Endpoint) *Endpoint
+}
+
+// roundRobin is a Balancer picking the endpoints in turn
+type roundRobin struct {
+	next uint64
+}
+
+// RoundRobin returns a Balancer picking the endpoints in turn
+func RoundRobin() Balancer {
+	return &roundRobin{}
+}
+
+// Pick returns the next endpoint
+func (b *roundRobin) Pick(endpoints []*Endpoint) *Endpoint {
+	return endpoints[(atomic.AddUint64(&b.next, 1)-1)%uint64(len(endpoints))]
+}
+
+// leastOutstanding is a Balancer picking the endpoint with the fewest outstanding requests
+type leastOutstanding struct {
+	next uint64
+}
+
+// LeastOutstanding returns a Balancer picking the endpoint with the fewest outstanding requests.
+// Ties are broken in turn.
+func LeastOutstanding() Balancer {
+	return &leastOutstanding{}
+}
+
+// Pick returns the endpoint with the fewest outstanding requests
+func (b *leastOutstanding) Pick(endpoints []*Endpoint) *Endpoint {
+	offset := int((atomic.AddUint64(&b.next, 1) - 1) % uint64(len(endpoints)))
+	var picked *Endpoint
+	for i := range endpoints {
+		endpoint := endpoints[(offset+i)%len(endpoints)]
+		if picked == nil || endpoint.Outstanding() < picked.Outstanding() {
+			picked = endpoint
+		}
+	}
+	return picked
+}
+
+// weightedRandom is a Balancer picking endpoints at random in proportion to their weights
+type weightedRandom struct{}
+
+// WeightedRandom returns a Balancer picking endpoints at random in proportion to their weights
+func WeightedRandom() Balancer {
+	return weightedRandom{}
+}
+
+// Pick returns a random endpoint
+func (weightedRandom) Pick(endpoints []*Endpoint) *Endpoint {
+	total := 0
+	for _, endpoint := range endpoints {
+		total += endpoint.Weight()
+	}
+	n := rand.Intn(total)
+	for _, endpoint := range endpoints {
+		if n -= endpoint.Weight(); n < 0 {
+			return endpoint
+		}
+	}
+	return endpoints[len(endpoints)-1]
+}
+
+// ReadStats contains the number of read requests, of reads that fell back to the master, of hedged reads
+// and of reads that shared the response of an identical read
+type ReadStats struct {
+	Reads        uint64
+	Fallbacks    uint64
+	Hedges       uint64
+	Deduplicated uint64
+}
+
+// replicaPool contains the replica endpoints serving read requests
+type replicaPool struct {
+	reads     uint64
+	fallbacks uint64
+	hedges    uint64
+	endpoints []*Endpoint
+	balancer  Balancer
+}
+
+// newReplicaPool returns the pool of SlaveBaseURL and the replicas of the config balanced by the config's
+// balancer, in round robin by default, and sharing the config's slave limits
+func newReplicaPool(config *Config) *replicaPool {
+	pool := &replicaPool{balancer: config.Balancer}
+	if pool.balancer == nil {
+		pool.balancer = RoundRobin()
+	}
+	pool.endpoints = append(pool.endpoints, newEndpoint(config.SlaveBaseURL, 1, false, config))
+	for _, replica := range config.Replicas {
+		pool.endpoints = append(pool.endpoints, newEndpoint(replica.BaseURL, replica.Weight, false, config))
+	}
+	slaveLimiter := newLimiter(config.SlaveLimits)
+	for _, endpoint := range pool.endpoints {
+		endpoint.limiter = slaveLimiter
+	}
+	return pool
+}
+
+// pick returns the endpoint chosen by the balancer among the healthy endpoints or,
+// when all of them are ejected, among all endpoints, in which case ok is false
+func (p *replicaPool) pick() (endpoint *Endpoint, ok bool) {
+	return p.pickExcept(nil)
+}
+
+// pickExcept is pick excluding the given endpoint
+func (p *replicaPool) pickExcept(excluded *Endpoint) (endpoint *Endpoint, ok bool) {
+	now := time.Now()
+	healthy := make([]*Endpoint, 0, len(p.endpoints))
+	for _, endpoint := range p.endpoints {
+		if endpoint != excluded && endpoint.healthy(now) {
+			healthy = append(healthy, endpoint)
+		}
+	}
+	ok = len(healthy) > 0
+	if !ok {
+		healthy = p.endpoints
+	}
+	if endpoint = p.balancer.Pick(healthy); endpoint == nil {
+		endpoint = healthy[0]
+	}
+	return endpoint, ok
+}
+
+// stats returns the read and fallback counts of the pool
+func (p *replicaPool) stats() ReadStats {
+	return ReadStats{
+		Reads:     atomic.LoadUint64(&p.reads),
+		Fallbacks: atomic.LoadUint64(&p.fallbacks),
+		Hedges:    atomic.LoadUint64(&p.hedges),
+	}
+}
+
+// ReadStats returns the read counts of the agent
+func (agent *Agent) ReadStats() ReadStats {
+	var stats ReadStats
+	if agent.replicas != nil {
+		stats = agent.replicas.stats()
+	}
+	if agent.flights != nil {
+		stats.Deduplicated = atomic.LoadUint64(&agent.flights.shared)
+	}
+	return stats
+}
+
+// masterEndpoint returns the endpoint of the master postgREST service
+func (agent *Agent) masterEndpoint() *Endpoint {
+	if agent.master == nil {
+		return newEndpoint(agent.config.MasterBaseURL, 1, true, agent.config)
+	}
+	return agent.master
+}
+
+// replicaPool returns the replica endpoints of the agent
+func (agent *Agent) replicaPool() *replicaPool {
+	if agent.replicas == nil {
+		return newReplicaPool(agent.config)
+	}
+	return agent.replicas
+}
+
+// call is a request for a path of the postgREST service
+type call struct {
+	attempts    int32
+	maxAttempts int32
+	method      string
+	path        string
+	query       *url.Values
+	header      http.Header
+	body        io.Reader
+}
+
+// sent returns the number of attempts sent for the call
+func (c *call) sent() int {
+	return int(atomic.LoadInt32(&c.attempts))
+}
+
+// canAttempt returns true if another attempt is within the attempt budget of the retry policy, if any
+func (c *call) canAttempt() bool {
+	max := atomic.LoadInt32(&c.maxAttempts)
+	return max <= 0 || atomic.LoadInt32(&c.attempts) < max
+}
+
+// read sends a read request to a replica endpoint, or to the master within the write window of the Session of ctx.
+// A read short-circuited by the breaker of its replica is sent to another healthy replica, if any.
+// With FallbackToMaster the request is sent to the master instead when all replicas are ejected
+// and sent again to the master when the replica fails with a connection error or a 5xx status.
+func (agent *Agent) read(ctx context.Context, c *call) (*http.Response, error) {
+	if session := SessionFromContext(ctx); session != nil && session.readsFromMaster() {
+		return agent.sendTo(ctx, agent.masterEndpoint(), c)
+	}
+	pool := agent.replicaPool()
+	atomic.AddUint64(&pool.reads, 1)
+	endpoint, ok := pool.pick()
+	if !ok && agent.config.FallbackToMaster {
+		atomic.AddUint64(&pool.fallbacks, 1)
+		return agent.sendTo(ctx, agent.masterEndpoint(), c)
+	}
+	response, err := agent.hedge(ctx, pool, endpoint, c)
+	if err == ErrCircuitOpen {
+		if other, ok := pool.pickExcept(endpoint); ok {
+			response, err = agent.hedge(ctx, pool, other, c)
+		}
+	}
+	if !agent.config.FallbackToMaster || ctx.Err() != nil || !isUnavailable(response, err) {
+		return response, err
+	}
+	if response != nil {
+		response.Body.Close()
+	}
+	atomic.AddUint64(&pool.fallbacks, 1)
+	return agent.sendTo(ctx, agent.masterEndpoint(), c)
+}
+
+// isUnavailable returns true if the request failed with a connection error, an open circuit breaker or a 5xx status
+func isUnavailable(response *http.Response, err error) bool {
+	if err == ErrCircuitOpen {
+		return true
+	}
+	if err != nil {
+		_, ok := err.(net.Error)
+		return ok
+	}
+	return response.StatusCode >= http.StatusInternalServerError
+}
+
+// newCallRequest returns the request for the call to endpoint, signed with the master token for the master
+// endpoint and with the slave token otherwise
+func (agent *Agent) newCallRequest(ctx context.Context, endpoint *Endpoint, c *call) (*http.Request, error) {
+	urlStr, err := buildURLStr(endpoint.baseURL, c.path, c.query)
+	if err != nil {
+		return nil, err
+	}
+	var request *http.Request
+	if endpoint.master {
+		request, err = agent.newWriteRequest(ctx, c.method, urlStr, c.body)
+	} else {
+		request, err = agent.newReadRequest(ctx, c.method, urlStr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range c.header {
+		for _, value := range values {
+			request.Header.Add(key, value)
+		}
+	}
+	return request, nil
+}
+
+// sendTo sends the request for the call to endpoint once allowed by the endpoint limits and circuit breaker,
+// and records its outcome in the endpoint health and circuit breaker
+func (agent *Agent) sendTo(ctx context.Context, endpoint *Endpoint, c *call) (*http.Response, error) {
+	request, err := agent.newCallRequest(ctx, endpoint, c)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := endpoint.limiter.wait(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return agent.sendAcquired(ctx, endpoint, c, request, limit)
+}
+
+// sendAcquired sends the request for the call to endpoint once allowed by its circuit breaker.
+// limit releases the in-flight slot acquired from the endpoint limiter.
+func (agent *Agent) sendAcquired(ctx context.Context, endpoint *Endpoint, c *call, request *http.Request, limit func()) (*http.Response, error) {
+	if err := endpoint.allow(); err != nil {
+		limit()
+		return nil, err
+	}
+	outstanding := endpoint.acquire()
+	release := func() {
+		outstanding()
+		limit()
+	}
+	atomic.AddInt32(&c.attempts, 1)
+	response, err := agent.httpClient.Do(request)
+	if ctx.Err() == nil {
+		endpoint.report(isUnavailable(response, err))
+	} else {
+		endpoint.abandon()
+	}
+	if err != nil {
+		release()
+		return nil, err
+	}
+	response.Body = &releasingBody{ReadCloser: response.Body, release: release}
+	return response, nil
+}
+
+// releasingBody is a response body releasing its outstanding request when closed
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+// Close closes the body and releases the outstanding request
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
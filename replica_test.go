@@ -0,0 +1,273 @@
+package postgrest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// replicaServers starts a server per name counting the requests it receives and failing
+// with 503 Service Unavailable while its name is in failing
+type replicaServers struct {
+	mu       sync.Mutex
+	servers  map[string]*httptest.Server
+	requests map[string]int
+	failing  map[string]bool
+}
+
+func newReplicaServers(names ...string) *replicaServers {
+	s := &replicaServers{servers: map[string]*httptest.Server{}, requests: map[string]int{}, failing: map[string]bool{}}
+	for _, name := range names {
+		name := name
+		s.servers[name] = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.requests[name+" "+r.Method+" "+r.Header.Get("Authorization")]++
+			if s.failing[name] {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte("[]"))
+		}))
+	}
+	return s
+}
+
+func (s *replicaServers) close() {
+	for _, server := range s.servers {
+		server.Close()
+	}
+}
+
+func (s *replicaServers) count(key string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[key]
+}
+
+func (s *replicaServers) setFailing(name string, failing bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failing[name] = failing
+}
+
+func TestBalancers(t *testing.T) {
+	t.Parallel()
+
+	config := &Config{}
+	a, b, c := newEndpoint("a", 1, false, config), newEndpoint("b", 3, false, config), newEndpoint("c", 0, false, config)
+	endpoints := []*Endpoint{a, b, c}
+
+	picked := ""
+	roundRobin := RoundRobin()
+	for i := 0; i < 4; i++ {
+		picked += roundRobin.Pick(endpoints).BaseURL()
+	}
+	if picked != "abca" {
+		t.Errorf("RoundRobin picked unexpected endpoints:\nExpected: %s\nGot: %s", "abca", picked)
+	}
+
+	releaseA, releaseC := a.acquire(), c.acquire()
+	b.acquire()
+	b.acquire()
+	if endpoint := LeastOutstanding().Pick(endpoints); endpoint != a && endpoint != c {
+		t.Errorf("LeastOutstanding picked unexpected endpoint: %s", endpoint.BaseURL())
+	}
+	releaseA()
+	releaseA()
+	if endpoint := LeastOutstanding().Pick(endpoints); endpoint != a || a.Outstanding() != 0 {
+		t.Errorf("LeastOutstanding picked unexpected endpoint:\nExpected: %s\nGot: %s", "a", endpoint.BaseURL())
+	}
+	releaseC()
+
+	counts := map[string]int{}
+	weighted := WeightedRandom()
+	for i := 0; i < 5000; i++ {
+		counts[weighted.Pick(endpoints).BaseURL()]++
+	}
+	if counts["b"] < 2*counts["a"] || counts["b"] < 2*counts["c"] || counts["a"] == 0 || counts["c"] == 0 {
+		t.Errorf("WeightedRandom picked endpoints out of proportion to weights 1, 3, 1: %v", counts)
+	}
+}
+
+func TestReplicaRouting(t *testing.T) {
+	t.Parallel()
+
+	servers := newReplicaServers("master", "slave", "replica")
+	defer servers.close()
+	config := &Config{
+		MasterBaseURL:     servers.servers["master"].URL,
+		MasterRole:        "master",
+		MasterSecret:      "master_secret",
+		SlaveBaseURL:      servers.servers["slave"].URL,
+		SlaveRole:         "slave",
+		SlaveSecret:       "slave_secret",
+		Timeout:           5,
+		Replicas:          []Replica{{BaseURL: servers.servers["replica"].URL}},
+		EjectionThreshold: 2,
+		EjectionTimeout:   time.Minute,
+		RetryPolicy:       &RetryPolicy{MaxAttempts: 1},
+	}
+	generator := func(_ interface{}, secret string) (string, error) { return secret, nil }
+	testAgent, err := NewAgent(config, &http.Client{}, generator)
+	if err != nil {
+		t.Fatalf("NewAgent returned unexpected error: %v", err)
+	}
+
+	for i := 0; i < 4; i++ {
+		testAgent.GetJSON("test_table", nil, nil)
+	}
+	testAgent.PostJSON("test_table", map[string]string{}, nil)
+	var expected = map[string]int{
+		"slave GET Bearer slave_secret":     2,
+		"replica GET Bearer slave_secret":   2,
+		"master POST Bearer master_secret":  1,
+		"master GET Bearer master_secret":   0,
+		"replica POST Bearer master_secret": 0,
+	}
+	for key, count := range expected {
+		if servers.count(key) != count {
+			t.Errorf("agent sent unexpected number of requests to %s:\nExpected: %d\nGot: %d", key, count, servers.count(key))
+		}
+	}
+
+	servers.setFailing("replica", true)
+	for i := 0; i < 6; i++ {
+		testAgent.GetJSON("test_table", nil, nil)
+	}
+	if count := servers.count("replica GET Bearer slave_secret"); count != 4 {
+		t.Errorf("agent sent requests to an ejected replica:\nExpected: %d\nGot: %d", 4, count)
+	}
+	if count := servers.count("slave GET Bearer slave_secret"); count != 6 {
+		t.Errorf("agent sent unexpected number of requests to the healthy replica:\nExpected: %d\nGot: %d", 6, count)
+	}
+
+	servers.setFailing("slave", true)
+	for i := 0; i < 2; i++ {
+		testAgent.GetJSON("test_table", nil, nil)
+	}
+	servers.setFailing("slave", false)
+	testAgent.GetJSON("test_table", nil, nil)
+	if count := servers.count("slave GET Bearer slave_secret") + servers.count("replica GET Bearer slave_secret"); count != 13 {
+		t.Errorf("agent sent unexpected number of requests with all replicas ejected:\nExpected: %d\nGot: %d", 13, count)
+	}
+
+	for _, endpoint := range testAgent.replicas.endpoints {
+		if endpoint.Outstanding() != 0 {
+			t.Errorf("endpoint %s has unexpected outstanding requests:\nExpected: %d\nGot: %d", endpoint.BaseURL(), 0, endpoint.Outstanding())
+		}
+	}
+}
+
+func TestEndpointHealth(t *testing.T) {
+	t.Parallel()
+
+	endpoint := newEndpoint("a", 0, false, &Config{EjectionThreshold: 2, EjectionTimeout: time.Minute})
+	now := time.Now()
+	endpoint.report(true)
+	endpoint.report(false)
+	endpoint.report(true)
+	if !endpoint.healthy(now) {
+		t.Error("endpoint was ejected without consecutive failures")
+	}
+	endpoint.report(true)
+	if endpoint.healthy(now) || endpoint.healthy(now.Add(59*time.Second)) {
+		t.Error("endpoint was not ejected after consecutive failures")
+	}
+	if !endpoint.healthy(now.Add(61 * time.Second)) {
+		t.Error("endpoint was not restored after the ejection timeout")
+	}
+}
+
+func TestConfigBalancer(t *testing.T) {
+	t.Parallel()
+
+	servers := newReplicaServers("slave", "replica")
+	defer servers.close()
+	testAgent, _ := NewAgent(&Config{
+		MasterBaseURL: servers.servers["slave"].URL,
+		MasterRole:    "master",
+		MasterSecret:  "master_secret",
+		SlaveBaseURL:  servers.servers["slave"].URL,
+		SlaveRole:     "slave",
+		SlaveSecret:   "slave_secret",
+		Timeout:       5,
+		Replicas:      []Replica{{BaseURL: servers.servers["replica"].URL}},
+		Balancer:      balancerFunc(func(endpoints []*Endpoint) *Endpoint { return endpoints[len(endpoints)-1] }),
+	}, &http.Client{}, nil)
+
+	for i := 0; i < 3; i++ {
+		response, _ := testAgent.Impersonate("user", nil).Get("test_table", nil)
+		response.Body.Close()
+	}
+	total := 0
+	for key, count := range servers.requests {
+		total += count
+		if !strings.HasPrefix(key, "replica GET") {
+			t.Errorf("agent sent unexpected request: %s", key)
+		}
+	}
+	if total != 3 {
+		t.Errorf("agent sent unexpected number of requests:\nExpected: %d\nGot: %d", 3, total)
+	}
+}
+
+// balancerFunc is a function used as a Balancer
+type balancerFunc func(endpoints []*Endpoint) *Endpoint
+
+func (f balancerFunc) Pick(endpoints []*Endpoint) *Endpoint {
+	return f(endpoints)
+}
+
+func TestReadFallback(t *testing.T) {
+	t.Parallel()
+
+	servers := newReplicaServers("master", "slave")
+	defer servers.close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	config := &Config{
+		MasterBaseURL:     servers.servers["master"].URL,
+		MasterRole:        "master",
+		MasterSecret:      "master_secret",
+		SlaveBaseURL:      servers.servers["slave"].URL,
+		SlaveRole:         "slave",
+		SlaveSecret:       "slave_secret",
+		Timeout:           5,
+		Replicas:          []Replica{{BaseURL: closed.URL}},
+		EjectionThreshold: 2,
+		FallbackToMaster:  true,
+		RetryPolicy:       &RetryPolicy{MaxAttempts: 1},
+	}
+	generator := func(_ interface{}, secret string) (string, error) { return secret, nil }
+	testAgent, _ := NewAgent(config, &http.Client{}, generator)
+	servers.setFailing("slave", true)
+
+	for i := 0; i < 6; i++ {
+		if _, err := testAgent.GetJSON("test_table", nil, nil); err != nil {
+			t.Errorf("GetJSON returned unexpected error: %v", err)
+		}
+	}
+	if count := servers.count("slave GET Bearer slave_secret"); count != 2 {
+		t.Errorf("agent sent unexpected number of requests to the failing replica:\nExpected: %d\nGot: %d", 2, count)
+	}
+	if count := servers.count("master GET Bearer master_secret"); count != 6 {
+		t.Errorf("agent sent unexpected number of fallback requests:\nExpected: %d\nGot: %d", 6, count)
+	}
+	if stats := testAgent.ReadStats(); stats != (ReadStats{Reads: 6, Fallbacks: 6}) {
+		t.Errorf("ReadStats returned unexpected counts:\nExpected: %v\nGot: %v", ReadStats{Reads: 6, Fallbacks: 6}, stats)
+	}
+
+	config.FallbackToMaster = false
+	servers.setFailing("master", true)
+	testAgent, _ = NewAgent(config, &http.Client{}, generator)
+	if _, err := testAgent.GetJSON("test_table", nil, nil); err == nil {
+		t.Error("GetJSON returned no error for a failing replica without fallback")
+	}
+	if stats := testAgent.ReadStats(); stats != (ReadStats{Reads: 1}) {
+		t.Errorf("ReadStats returned unexpected counts:\nExpected: %v\nGot: %v", ReadStats{Reads: 1}, stats)
+	}
+}
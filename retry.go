@@ -0,0 +1,165 @@
+package postgrest
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// idempotentKey is the context key marking writes as idempotent
+type idempotentKey struct{}
+
+// RetryPolicy configures the retries of requests failing with a connection error or a retryable status.
+// It applies to GET and HEAD requests and to writes made with a context marked by WithIdempotent.
+// A MaxAttempts of 1 disables retries.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first one (3 by default)
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry (100ms by default)
+	InitialBackoff time.Duration
+	// MaxBackoff caps the exponential backoff between attempts (5s by default)
+	MaxBackoff time.Duration
+	// Multiplier is the factor applied to the backoff after each attempt (2 by default)
+	Multiplier float64
+	// Jitter is the fraction of the backoff randomly added or removed, between 0 and 1
+	Jitter float64
+	// RetryableStatusCodes are the response statuses that are retried (502, 503 and 504 by default)
+	RetryableStatusCodes []int
+}
+
+// DefaultRetryPolicy returns a RetryPolicy making up to 3 attempts with a jittered exponential backoff
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{MaxAttempts: 3, Jitter: 0.2}
+}
+
+// WithIdempotent returns a copy of ctx marking the writes made with it as idempotent, and so retryable
+func WithIdempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, idempotentKey{}, true)
+}
+
+// isIdempotent returns true if ctx is marked by WithIdempotent
+func isIdempotent(ctx context.Context) bool {
+	idempotent, _ := ctx.Value(idempotentKey{}).(bool)
+	return idempotent
+}
+
+// newRetryPolicy returns a copy of policy, or of the DefaultRetryPolicy when nil, with the defaults
+// of its unset fields
+func newRetryPolicy(policy *RetryPolicy) *RetryPolicy {
+	if policy == nil {
+		policy = DefaultRetryPolicy()
+	}
+	p := *policy
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 3
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = 100 * time.Millisecond
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = 5 * time.Second
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = 2
+	}
+	if p.RetryableStatusCodes == nil {
+		p.RetryableStatusCodes = []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+	}
+	p.RetryableStatusCodes = append([]int{}, p.RetryableStatusCodes...)
+	return &p
+}
+
+// retry makes the attempts of the call allowed by the retry policy of the agent, hedged reads included.
+// The body of the call is buffered so that each attempt resends it.
+func (agent *Agent) retry(ctx context.Context, c *call, attempt func() (*http.Response, error)) (*http.Response, error) {
+	policy := agent.retryPolicy
+	if policy == nil || (c.method != http.MethodGet && c.method != http.MethodHead && !isIdempotent(ctx)) {
+		return attempt()
+	}
+	atomic.StoreInt32(&c.maxAttempts, int32(policy.MaxAttempts))
+	if policy.MaxAttempts <= 1 {
+		return attempt()
+	}
+	var body []byte
+	if c.body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(c.body); err != nil {
+			return nil, err
+		}
+	}
+
+	backoff := policy.InitialBackoff
+	for i := 1; ; i++ {
+		if c.body != nil {
+			c.body = bytes.NewReader(body)
+		}
+		response, err := attempt()
+		if i >= policy.MaxAttempts || c.sent() >= policy.MaxAttempts || ctx.Err() != nil || !policy.retryable(response, err) {
+			return response, err
+		}
+		delay := policy.jitter(backoff)
+		if response != nil {
+			if retryAfter := parseRetryAfter(response.Header.Get("Retry-After"), time.Now()); retryAfter > delay {
+				delay = retryAfter
+			}
+			io.Copy(ioutil.Discard, io.LimitReader(response.Body, maxErrorBodySize))
+			response.Body.Close()
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		if backoff = time.Duration(float64(backoff) * policy.Multiplier); backoff > policy.MaxBackoff {
+			backoff = policy.MaxBackoff
+		}
+	}
+}
+
+// retryable returns true if the attempt failed with a connection error or a retryable status
+func (p *RetryPolicy) retryable(response *http.Response, err error) bool {
+	if err != nil {
+		_, ok := err.(net.Error)
+		return ok
+	}
+	for _, code := range p.RetryableStatusCodes {
+		if response.StatusCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+// jitter randomly adds or removes up to the Jitter fraction of backoff
+func (p *RetryPolicy) jitter(backoff time.Duration) time.Duration {
+	if p.Jitter <= 0 {
+		return backoff
+	}
+	return time.Duration(float64(backoff) * (1 + p.Jitter*(2*rand.Float64()-1)))
+}
+
+// parseRetryAfter returns the delay of a Retry-After header in seconds or as an http date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,169 @@
+package postgrest
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	var tests = []struct {
+		value    string
+		expected time.Duration
+	}{
+		{"", 0},
+		{"3", 3 * time.Second},
+		{"-1", 0},
+		{"Sun, 01 Jan 2017 00:00:10 GMT", 10 * time.Second},
+		{"Sat, 31 Dec 2016 23:59:00 GMT", 0},
+		{"soon", 0},
+	}
+	for _, test := range tests {
+		if delay := parseRetryAfter(test.value, now); delay != test.expected {
+			t.Errorf("parseRetryAfter returned unexpected delay for %q:\nExpected: %v\nGot: %v", test.value, test.expected, delay)
+		}
+	}
+}
+
+func TestRetryPolicyJitter(t *testing.T) {
+	t.Parallel()
+
+	policy := &RetryPolicy{Jitter: 0.5}
+	for i := 0; i < 100; i++ {
+		if delay := policy.jitter(time.Second); delay < 500*time.Millisecond || delay > 1500*time.Millisecond {
+			t.Errorf("jitter returned delay out of bounds: %v", delay)
+		}
+	}
+	if delay := (&RetryPolicy{}).jitter(time.Second); delay != time.Second {
+		t.Errorf("jitter returned unexpected delay:\nExpected: %v\nGot: %v", time.Second, delay)
+	}
+}
+
+func TestNewRetryPolicy(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		policy   *RetryPolicy
+		expected int
+	}{
+		{nil, 3},
+		{&RetryPolicy{InitialBackoff: time.Second}, 3},
+		{&RetryPolicy{MaxAttempts: -1}, 3},
+		{&RetryPolicy{MaxAttempts: 1}, 1},
+		{&RetryPolicy{MaxAttempts: 5}, 5},
+	}
+	for _, test := range tests {
+		if policy := newRetryPolicy(test.policy); policy.MaxAttempts != test.expected {
+			t.Errorf("newRetryPolicy returned unexpected MaxAttempts for %+v:\nExpected: %d\nGot: %d", test.policy, test.expected, policy.MaxAttempts)
+		}
+	}
+	if policy := newRetryPolicy(&RetryPolicy{}); policy.InitialBackoff != 100*time.Millisecond || policy.MaxBackoff != 5*time.Second || policy.Multiplier != 2 {
+		t.Errorf("newRetryPolicy returned unexpected defaults: %+v", policy)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var failures int
+	var bodies []string
+	retryServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, r.Method+" "+string(body))
+		if failures > 0 {
+			failures--
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("[]"))
+	}))
+	defer retryServer.Close()
+	fail := func(n int) {
+		mu.Lock()
+		defer mu.Unlock()
+		failures = n
+		bodies = nil
+	}
+	requests := func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		return strings.Join(bodies, "|")
+	}
+
+	newRetryAgent := func(policy *RetryPolicy) *Agent {
+		testAgent, _ := NewAgent(&Config{
+			MasterBaseURL: retryServer.URL,
+			MasterRole:    "master",
+			MasterSecret:  "master_secret",
+			SlaveBaseURL:  retryServer.URL,
+			SlaveRole:     "slave",
+			SlaveSecret:   "slave_secret",
+			Timeout:       5,
+			RetryPolicy:   policy,
+		}, &http.Client{}, nil)
+		return testAgent
+	}
+
+	testAgent := newRetryAgent(&RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond})
+
+	fail(2)
+	if status, err := testAgent.GetJSON("test_table", nil, nil); err != nil || status != http.StatusOK {
+		t.Errorf("GetJSON returned unexpected result:\nExpected: %d\nGot: %d %v", http.StatusOK, status, err)
+	}
+	if requests() != "GET |GET |GET " {
+		t.Errorf("GetJSON made unexpected attempts: %s", requests())
+	}
+
+	fail(3)
+	if status, _ := testAgent.GetJSON("test_table", nil, nil); status != http.StatusServiceUnavailable {
+		t.Errorf("GetJSON returned unexpected status:\nExpected: %d\nGot: %d", http.StatusServiceUnavailable, status)
+	}
+
+	fail(1)
+	if response, _ := testAgent.Post("test_table", strings.NewReader(`{"a":1}`)); response.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Post returned unexpected status:\nExpected: %d\nGot: %d", http.StatusServiceUnavailable, response.StatusCode)
+	}
+	if requests() != `POST {"a":1}` {
+		t.Errorf("Post retried a write not marked idempotent: %s", requests())
+	}
+
+	fail(1)
+	if _, err := testAgent.PatchJSONContext(WithIdempotent(context.Background()), "test_table", nil, map[string]int{"a": 1}); err != nil {
+		t.Errorf("PatchJSONContext returned unexpected error: %v", err)
+	}
+	if requests() != "PATCH {\"a\":1}\n|PATCH {\"a\":1}\n" {
+		t.Errorf("PatchJSONContext made unexpected attempts: %q", requests())
+	}
+
+	testAgent = newRetryAgent(&RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Hour})
+	fail(1)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := testAgent.GetContext(ctx, "test_table", nil); err != context.DeadlineExceeded {
+		t.Errorf("GetContext returned unexpected error:\nExpected: %v\nGot: %v", context.DeadlineExceeded, err)
+	}
+
+	testAgent = newRetryAgent(&RetryPolicy{MaxAttempts: 1})
+	fail(1)
+	if status, _ := testAgent.GetJSON("test_table", nil, nil); status != http.StatusServiceUnavailable || requests() != "GET " {
+		t.Errorf("GetJSON retried with retries disabled: %s", requests())
+	}
+
+	testAgent = newRetryAgent(nil)
+	fail(1)
+	if status, err := testAgent.GetJSON("test_table", nil, nil); err != nil || status != http.StatusOK || requests() != "GET |GET " {
+		t.Errorf("GetJSON was not retried by the default retry policy: %d %v %s", status, err, requests())
+	}
+}
@@ -0,0 +1,136 @@
+package postgrest
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// rpcPath returns the path of the postgREST endpoint exposing the postgreSQL function fn
+func rpcPath(fn string) (string, error) {
+	if fn == "" {
+		return "", errMissingFunctionName
+	}
+	return "rpc/" + fn, nil
+}
+
+// RPC makes an HTTP POST request calling the postgreSQL function fn on the postgREST master service.
+// The body contains the function arguments as a JSON object, or the value of the argument of
+// a function with a single unnamed json, jsonb, text or bytea parameter.
+func (agent *Agent) RPC(fn string, body io.Reader) (*http.Response, error) {
+	return agent.RPCContext(context.Background(), fn, body)
+}
+
+// RPCContext is RPC with the given context attached to the request
+func (agent *Agent) RPCContext(ctx context.Context, fn string, body io.Reader) (*http.Response, error) {
+	path, err := rpcPath(fn)
+	if err != nil {
+		return nil, err
+	}
+	return agent.send(ctx, http.MethodPost, path, nil, nil, body)
+}
+
+// RPCJSON calls the postgreSQL function fn with the JSON encoded args on the postgREST master service
+// and unmarshals the result into the given target interface. Scalar functions decode into a scalar
+// target and set returning functions into a slice.
+// Returns error if the response status code is not inclusively between 200 and 299
+func (agent *Agent) RPCJSON(fn string, args interface{}, target interface{}) (int, error) {
+	return agent.RPCJSONContext(context.Background(), fn, args, target)
+}
+
+// RPCJSONContext is RPCJSON with the given context attached to the request
+func (agent *Agent) RPCJSONContext(ctx context.Context, fn string, args interface{}, target interface{}) (int, error) {
+	if args == nil {
+		args = struct{}{}
+	}
+	body, err := jsonEncode(args)
+	if err != nil {
+		return 0, err
+	}
+	response, err := agent.RPCContext(ctx, fn, body)
+	if err != nil {
+		return 0, err
+	}
+	return unmarshalResponse(response, target)
+}
+
+// RPCGet makes an HTTP GET request calling the immutable or stable postgreSQL function fn
+// on the postgREST slave service. The function arguments are passed in args.
+func (agent *Agent) RPCGet(fn string, args *url.Values) (*http.Response, error) {
+	return agent.RPCGetContext(context.Background(), fn, args)
+}
+
+// RPCGetContext is RPCGet with the given context attached to the request
+func (agent *Agent) RPCGetContext(ctx context.Context, fn string, args *url.Values) (*http.Response, error) {
+	path, err := rpcPath(fn)
+	if err != nil {
+		return nil, err
+	}
+	return agent.send(ctx, http.MethodGet, path, args, nil, nil)
+}
+
+// RPCGetJSON calls the immutable or stable postgreSQL function fn on the postgREST slave service
+// and unmarshals the result into the given target interface
+// Returns error if the response status code is not inclusively between 200 and 299
+func (agent *Agent) RPCGetJSON(fn string, args *url.Values, target interface{}) (int, error) {
+	return agent.RPCGetJSONContext(context.Background(), fn, args, target)
+}
+
+// RPCGetJSONContext is RPCGetJSON with the given context attached to the request
+func (agent *Agent) RPCGetJSONContext(ctx context.Context, fn string, args *url.Values, target interface{}) (int, error) {
+	response, err := agent.RPCGetContext(ctx, fn, args)
+	if err != nil {
+		return 0, err
+	}
+	return unmarshalResponse(response, target)
+}
+
+// Function returns a new Query for the postgreSQL function fn. The filters, order and limit of the query
+// are applied to the rows returned by set returning functions. Immutable and stable functions are
+// called on the slave service with Get/GetJSON, others on the master service with Call/CallJSON.
+func (agent *Agent) Function(fn string) *Query {
+	q := agent.From("rpc/" + fn)
+	if fn == "" {
+		q.err = errMissingFunctionName
+	}
+	return q
+}
+
+// Arg sets a function argument passed in the query string when the function is called with Get/GetJSON
+func (q *Query) Arg(name string, value interface{}) *Query {
+	q.params.Add(name, formatValue(value))
+	return q
+}
+
+// ContentType sets the Content-Type of the request body e.g: "text/plain" or "application/octet-stream"
+// when calling functions with a single unnamed text or bytea parameter
+func (q *Query) ContentType(contentType string) *Query {
+	q.header.Set("Content-Type", contentType)
+	return q
+}
+
+// Call makes an HTTP POST request for the query to the postgREST master service
+func (q *Query) Call(body io.Reader) (*http.Response, error) {
+	if q.err != nil {
+		return nil, q.err
+	}
+	return q.agent.send(q.ctx, http.MethodPost, q.table, q.Values(), q.requestHeader(), body)
+}
+
+// CallJSON makes an HTTP POST request for the query with the JSON encoded args
+// and unmarshals the response into the given target interface
+func (q *Query) CallJSON(args interface{}, target interface{}) (int, error) {
+	if args == nil {
+		args = struct{}{}
+	}
+	body, err := jsonEncode(args)
+	if err != nil {
+		return 0, err
+	}
+	response, err := q.Call(body)
+	if err != nil {
+		return 0, err
+	}
+	return unmarshalResponse(response, target)
+}
@@ -0,0 +1,133 @@
+package postgrest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newRPCServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		switch r.URL.Path {
+		case "/rpc/add":
+			if r.Method != http.MethodPost || string(body) != "{\"a\":1,\"b\":2}\n" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, "3")
+		case "/rpc/now":
+			if r.Method != http.MethodPost || string(body) != "{}\n" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, `"2017-01-01"`)
+		case "/rpc/upper":
+			if r.Header.Get("Content-Type") != "text/plain" {
+				w.WriteHeader(http.StatusUnsupportedMediaType)
+				return
+			}
+			fmt.Fprintf(w, "%q", strings.ToUpper(string(body)))
+		case "/rpc/search":
+			if r.Method != http.MethodGet {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			if r.Header.Get("Accept") == "application/vnd.pgrst.object+json" {
+				fmt.Fprintf(w, `{"query":%q}`, r.URL.RawQuery)
+				return
+			}
+			fmt.Fprintf(w, `[{"query":%q}]`, r.URL.RawQuery)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestRPC(t *testing.T) {
+	t.Parallel()
+
+	rpcServer := newRPCServer()
+	defer rpcServer.Close()
+	testAgent := newTestAgent(rpcServer.URL)
+
+	var sum int
+	status, err := testAgent.RPCJSON("add", map[string]int{"a": 1, "b": 2}, &sum)
+	if err != nil {
+		t.Errorf("RPCJSON returned unexpected error: %v", err)
+	}
+	if status != http.StatusOK || sum != 3 {
+		t.Errorf("RPCJSON returned unexpected results:\nExpected: %d %d\nGot: %d %d", http.StatusOK, 3, status, sum)
+	}
+
+	var now string
+	if _, err = testAgent.RPCJSON("now", nil, &now); err != nil || now != "2017-01-01" {
+		t.Errorf("RPCJSON returned unexpected results:\nExpected: %s\nGot: %s %v", "2017-01-01", now, err)
+	}
+
+	args := &url.Values{}
+	args.Set("term", "test")
+	results := []map[string]string{}
+	if _, err = testAgent.RPCGetJSON("search", args, &results); err != nil {
+		t.Errorf("RPCGetJSON returned unexpected error: %v", err)
+	}
+	expected := []map[string]string{{"query": "term=test"}}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("RPCGetJSON returned unexpected results:\nExpected: %v\nGot: %v", expected, results)
+	}
+
+	response, err := testAgent.RPC("missing", nil)
+	if err != nil || response.StatusCode != http.StatusNotFound {
+		t.Errorf("RPC returned unexpected results:\nExpected: %d\nGot: %v %v", http.StatusNotFound, response, err)
+	}
+
+	if _, err = testAgent.RPCGet("", nil); err == nil || err.Error() != errMissingFunctionName.Error() {
+		t.Errorf("RPCGet returned unexpected error:\nExpected: %v\nGot: %v", errMissingFunctionName, err)
+	}
+}
+
+func TestFunctionQuery(t *testing.T) {
+	t.Parallel()
+
+	rpcServer := newRPCServer()
+	defer rpcServer.Close()
+	testAgent := newTestAgent(rpcServer.URL)
+
+	results := []map[string]string{}
+	_, err := testAgent.Function("search").Arg("term", "test").Gt("rank", 1).Order("rank", Desc).Limit(2).GetJSON(&results)
+	if err != nil {
+		t.Errorf("GetJSON returned unexpected error: %v", err)
+	}
+	expected := []map[string]string{{"query": "limit=2&order=rank.desc&rank=gt.1&term=test"}}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("GetJSON returned unexpected results:\nExpected: %v\nGot: %v", expected, results)
+	}
+
+	result := map[string]string{}
+	if _, err = testAgent.Function("search").Single().GetJSON(&result); err != nil || result["query"] != "" {
+		t.Errorf("GetJSON returned unexpected results:\nExpected: %v\nGot: %v %v", map[string]string{"query": ""}, result, err)
+	}
+
+	var upper string
+	response, err := testAgent.Function("upper").ContentType("text/plain").Call(strings.NewReader("test"))
+	if err != nil {
+		t.Errorf("Call returned unexpected error: %v", err)
+	}
+	if _, err = unmarshalResponse(response, &upper); err != nil || upper != "TEST" {
+		t.Errorf("Call returned unexpected results:\nExpected: %s\nGot: %s %v", "TEST", upper, err)
+	}
+
+	var sum int
+	if _, err = testAgent.Function("add").CallJSON(map[string]int{"a": 1, "b": 2}, &sum); err != nil || sum != 3 {
+		t.Errorf("CallJSON returned unexpected results:\nExpected: %d\nGot: %d %v", 3, sum, err)
+	}
+
+	if _, err = testAgent.Function("").CallJSON(nil, nil); err == nil || err.Error() != errMissingFunctionName.Error() {
+		t.Errorf("CallJSON returned unexpected error:\nExpected: %v\nGot: %v", errMissingFunctionName, err)
+	}
+}
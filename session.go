@@ -0,0 +1,49 @@
+package postgrest
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sessionKey is the context key of the Session
+type sessionKey struct{}
+
+// Session provides read-your-writes consistency to the requests of a unit of work, e.g: an http handler.
+// Reads made with a context carrying the session are sent to the master, instead of a replica that may
+// lag behind, for a window of time after each write made with such a context.
+type Session struct {
+	window    time.Duration
+	mu        sync.Mutex
+	lastWrite time.Time
+}
+
+// NewSession returns a Session sending reads to the master for window after each write
+func NewSession(window time.Duration) *Session {
+	return &Session{window: window}
+}
+
+// WithSession returns a copy of ctx carrying the session
+func WithSession(ctx context.Context, session *Session) context.Context {
+	return context.WithValue(ctx, sessionKey{}, session)
+}
+
+// SessionFromContext returns the session carried by ctx or nil
+func SessionFromContext(ctx context.Context) *Session {
+	session, _ := ctx.Value(sessionKey{}).(*Session)
+	return session
+}
+
+// wrote records a write made in the session
+func (s *Session) wrote() {
+	s.mu.Lock()
+	s.lastWrite = time.Now()
+	s.mu.Unlock()
+}
+
+// readsFromMaster returns true if the session made a write within its window
+func (s *Session) readsFromMaster() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.lastWrite.IsZero() && time.Since(s.lastWrite) < s.window
+}
@@ -0,0 +1,69 @@
+package postgrest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSessionFromContext(t *testing.T) {
+	t.Parallel()
+
+	session := NewSession(time.Minute)
+	if SessionFromContext(context.Background()) != nil {
+		t.Error("SessionFromContext returned a session for a context without one")
+	}
+	if SessionFromContext(WithSession(context.Background(), session)) != session {
+		t.Error("SessionFromContext did not return the session of the context")
+	}
+	if session.readsFromMaster() {
+		t.Error("session reads from the master before any write")
+	}
+	session.wrote()
+	if !session.readsFromMaster() {
+		t.Error("session does not read from the master after a write")
+	}
+	expired := NewSession(time.Millisecond)
+	expired.wrote()
+	time.Sleep(5 * time.Millisecond)
+	if expired.readsFromMaster() {
+		t.Error("session reads from the master after its window")
+	}
+}
+
+func TestReadYourWrites(t *testing.T) {
+	t.Parallel()
+
+	servers := newReplicaServers("master", "slave")
+	defer servers.close()
+	generator := func(_ interface{}, secret string) (string, error) { return secret, nil }
+	testAgent, _ := NewAgent(&Config{
+		MasterBaseURL: servers.servers["master"].URL,
+		MasterRole:    "master",
+		MasterSecret:  "master_secret",
+		SlaveBaseURL:  servers.servers["slave"].URL,
+		SlaveRole:     "slave",
+		SlaveSecret:   "slave_secret",
+		Timeout:       5,
+	}, &http.Client{}, generator)
+
+	ctx := WithSession(context.Background(), NewSession(time.Minute))
+	testAgent.GetJSONContext(ctx, "test_table", nil, nil)
+	testAgent.PostJSONContext(ctx, "test_table", map[string]string{}, nil)
+	testAgent.GetJSONContext(ctx, "test_table", nil, nil)
+	testAgent.From("test_table").WithContext(ctx).GetJSON(nil)
+	testAgent.GetJSON("test_table", nil, nil)
+	testAgent.GetJSONContext(WithSession(context.Background(), NewSession(time.Minute)), "test_table", nil, nil)
+
+	var expected = map[string]int{
+		"slave GET Bearer slave_secret":    3,
+		"master POST Bearer master_secret": 1,
+		"master GET Bearer master_secret":  2,
+	}
+	for key, count := range expected {
+		if servers.count(key) != count {
+			t.Errorf("agent sent unexpected number of requests to %s:\nExpected: %d\nGot: %d", key, count, servers.count(key))
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package postgrest
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// flightGroup collapses identical concurrent reads into a single request
+type flightGroup struct {
+	shared  uint64
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+// flight is an in-flight read whose response is shared with the identical reads made meanwhile
+type flight struct {
+	done     chan struct{}
+	response *http.Response
+	body     []byte
+	err      error
+	canceled bool
+}
+
+// newFlightGroup returns an empty flightGroup
+func newFlightGroup() *flightGroup {
+	return &flightGroup{flights: map[string]*flight{}}
+}
+
+// dedupe sends the read of the call or waits for the response of an identical read in flight, i.e: with
+// the same url, headers and authorization token and so role and claims. All of them receive a copy of its body.
+// Reads made within the write window of a Session are not deduplicated.
+func (agent *Agent) dedupe(ctx context.Context, c *call, read func() (*http.Response, error)) (*http.Response, error) {
+	if session := SessionFromContext(ctx); session != nil && session.readsFromMaster() {
+		return read()
+	}
+	tokenStr, err := agent.generateReadTokenStr()
+	if err != nil {
+		return nil, err
+	}
+	key := bytes.NewBufferString(c.path)
+	if c.query != nil {
+		key.WriteString("?" + c.query.Encode())
+	}
+	key.WriteString("\n" + tokenStr + "\n")
+	c.header.Write(key)
+	return agent.flights.do(ctx, key.String(), read)
+}
+
+// do returns the response of the read in flight for key, calling read when there is none.
+// A waiter whose flight was canceled by the context of its caller calls its own read.
+func (g *flightGroup) do(ctx context.Context, key string, read func() (*http.Response, error)) (*http.Response, error) {
+	g.mu.Lock()
+	if f, ok := g.flights[key]; ok {
+		g.mu.Unlock()
+		select {
+		case <-f.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if f.canceled {
+			return read()
+		}
+		atomic.AddUint64(&g.shared, 1)
+		return f.result()
+	}
+	f := &flight{done: make(chan struct{})}
+	g.flights[key] = f
+	g.mu.Unlock()
+
+	response, err := read()
+	if err == nil {
+		f.body, err = ioutil.ReadAll(response.Body)
+		response.Body.Close()
+		f.response = response
+	}
+	f.err, f.canceled = err, ctx.Err() != nil
+
+	g.mu.Lock()
+	delete(g.flights, key)
+	g.mu.Unlock()
+	close(f.done)
+	return f.result()
+}
+
+// result returns a copy of the response of the flight
+func (f *flight) result() (*http.Response, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	response := *f.response
+	response.Header = cloneHeader(f.response.Header)
+	response.Body = ioutil.NopCloser(bytes.NewReader(f.body))
+	response.ContentLength = int64(len(f.body))
+	return &response, nil
+}
@@ -0,0 +1,139 @@
+package postgrest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDeduplication(t *testing.T) {
+	t.Parallel()
+
+	var requests int32
+	release := make(chan struct{})
+	flightServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			return
+		}
+		w.Write([]byte(`[{"id":1}]`))
+	}))
+	defer flightServer.Close()
+
+	testAgent, _ := NewAgent(&Config{
+		MasterBaseURL: flightServer.URL,
+		MasterRole:    "master",
+		MasterSecret:  "master_secret",
+		SlaveBaseURL:  flightServer.URL,
+		SlaveRole:     "slave",
+		SlaveSecret:   "slave_secret",
+		Timeout:       5,
+		Deduplicate:   true,
+	}, &http.Client{}, nil)
+	userAgent := testAgent.Impersonate("", map[string]interface{}{"user_id": 1})
+
+	var wg sync.WaitGroup
+	get := func(ctx context.Context, agent *Agent) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rows := []map[string]int{}
+			if _, err := agent.From("test_table").WithContext(ctx).Eq("id", 1).GetJSON(&rows); err != nil || len(rows) != 1 || rows[0]["id"] != 1 {
+				t.Errorf("GetJSON returned unexpected rows: %v %v", rows, err)
+			}
+		}()
+	}
+	get(context.Background(), testAgent)
+	get(context.Background(), userAgent)
+	waitUntil(t, "both requests are sent", func() bool { return atomic.LoadInt32(&requests) == 2 })
+	for i := 0; i < 4; i++ {
+		agent := testAgent
+		if i%2 == 1 {
+			agent = userAgent
+		}
+		ctx := newJoinContext()
+		get(ctx, agent)
+		<-ctx.joined
+	}
+	close(release)
+	wg.Wait()
+
+	if count := atomic.LoadInt32(&requests); count != 2 {
+		t.Errorf("agent sent unexpected number of requests:\nExpected: %d\nGot: %d", 2, count)
+	}
+	if stats := testAgent.ReadStats(); stats.Deduplicated != 4 {
+		t.Errorf("ReadStats returned unexpected deduplicated reads:\nExpected: %d\nGot: %d", 4, stats.Deduplicated)
+	}
+}
+
+func TestFlightGroup(t *testing.T) {
+	t.Parallel()
+
+	group := newFlightGroup()
+	started, release := make(chan struct{}), make(chan struct{})
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+	var leaderErr error
+	done := make(chan struct{})
+	go func() {
+		_, leaderErr = group.do(leaderCtx, "key", func() (*http.Response, error) {
+			close(started)
+			<-release
+			return nil, leaderCtx.Err()
+		})
+		close(done)
+	}()
+	<-started
+
+	waiterCtx, cancelWaiter := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancelWaiter()
+	if _, err := group.do(waiterCtx, "key", nil); err != context.DeadlineExceeded {
+		t.Errorf("do returned unexpected error:\nExpected: %v\nGot: %v", context.DeadlineExceeded, err)
+	}
+
+	own := make(chan bool, 1)
+	ownCtx := newJoinContext()
+	go func() {
+		response, err := group.do(ownCtx, "key", func() (*http.Response, error) {
+			own <- true
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Header: http.Header{}}, nil
+		})
+		if err != nil || response.StatusCode != http.StatusOK {
+			t.Errorf("do returned unexpected response: %v %v", response, err)
+		}
+		close(own)
+	}()
+	<-ownCtx.joined
+	cancelLeader()
+	close(release)
+	<-done
+	if leaderErr != context.Canceled {
+		t.Errorf("do returned unexpected error:\nExpected: %v\nGot: %v", context.Canceled, leaderErr)
+	}
+	if !<-own {
+		t.Error("waiter of a canceled flight did not send its own read")
+	}
+}
+
+// joinContext is a context signaling when a read waits for a flight, which is when it first checks whether it is done
+type joinContext struct {
+	context.Context
+	once   sync.Once
+	joined chan struct{}
+}
+
+// newJoinContext returns a joinContext without deadline
+func newJoinContext() *joinContext {
+	return &joinContext{Context: context.Background(), joined: make(chan struct{})}
+}
+
+// Done closes joined on its first call
+func (ctx *joinContext) Done() <-chan struct{} {
+	ctx.once.Do(func() { close(ctx.joined) })
+	return ctx.Context.Done()
+}
@@ -0,0 +1,163 @@
+package postgrest
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// columnTypes are the interfaces of the struct types decoded from a single column rather than embedded
+var columnTypes = []reflect.Type{
+	reflect.TypeOf((*json.Unmarshaler)(nil)).Elem(),
+	reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem(),
+	reflect.TypeOf((*sql.Scanner)(nil)).Elem(),
+	reflect.TypeOf((*driver.Valuer)(nil)).Elem(),
+}
+
+// SelectFor derives a postgREST select list from the struct tags of target, which must be
+// a struct or a slice of structs (or pointers to them).
+//
+// Columns are named by the `json` tag of each field. The `postgrest` tag selects a different
+// source column, which is renamed to the json name in the response:
+//
+//	Name string `json:"name" postgrest:"full_name"`      // name:full_name
+//	Name string `json:"name" postgrest:"name:full_name"` // name:full_name
+//
+// Struct and slice of struct fields are embedded resources. Hints and inner joins are given in the tag:
+//
+//	Orders []order `json:"orders" postgrest:"orders!fk_name,inner"` // orders!fk_name!inner(...)
+//
+// Structs implementing json.Unmarshaler, encoding.TextUnmarshaler, sql.Scanner or driver.Valuer, e.g:
+// time.Time or sql.NullString, are columns, as are the fields tagged with the column option:
+//
+//	Meta meta `json:"meta" postgrest:",column"` // meta
+//
+// Fields tagged with `postgrest:"-"` or `json:"-"` are skipped.
+func SelectFor(target interface{}) (string, error) {
+	structType := structOf(reflect.TypeOf(target))
+	if structType == nil {
+		return "", errInvalidSelectTarget
+	}
+	items, err := selectItems(structType, map[reflect.Type]bool{})
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(items, ","), nil
+}
+
+// SelectFor sets the `select` parameter of the query to the select list derived from target
+func (q *Query) SelectFor(target interface{}) *Query {
+	selectStr, err := SelectFor(target)
+	if err != nil {
+		q.err = err
+		return q
+	}
+	return q.Select(selectStr)
+}
+
+// structOf returns the struct type behind pointers, slices and arrays of t or nil if there is none
+func structOf(t reflect.Type) reflect.Type {
+	for t != nil {
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Array:
+			if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+				return nil
+			}
+			t = t.Elem()
+		case reflect.Struct:
+			if isColumnType(t) {
+				return nil
+			}
+			return t
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// isColumnType returns true if the struct type t, or a pointer to it, is decoded from a single column
+func isColumnType(t reflect.Type) bool {
+	for _, columnType := range columnTypes {
+		if t.Implements(columnType) || reflect.PtrTo(t).Implements(columnType) {
+			return true
+		}
+	}
+	return false
+}
+
+// selectItems returns the select list items for the fields of structType
+func selectItems(structType reflect.Type, visiting map[reflect.Type]bool) ([]string, error) {
+	if visiting[structType] {
+		return nil, fmt.Errorf("postgrest error: recursive type %s in select target", structType)
+	}
+	visiting[structType] = true
+	defer delete(visiting, structType)
+
+	var items []string
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		jsonName := strings.Split(field.Tag.Get("json"), ",")[0]
+		tag := strings.Split(field.Tag.Get("postgrest"), ",")
+		if jsonName == "-" || tag[0] == "-" {
+			continue
+		}
+
+		fieldStruct := structOf(field.Type)
+		for _, option := range tag[1:] {
+			if option == "column" {
+				fieldStruct = nil
+			}
+		}
+		if field.Anonymous && jsonName == "" && fieldStruct != nil {
+			embedded, err := selectItems(fieldStruct, visiting)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, embedded...)
+			continue
+		}
+		if jsonName == "" {
+			jsonName = field.Name
+		}
+		source := tag[0]
+		if source == "" {
+			source = jsonName
+		}
+		if fieldStruct == nil {
+			items = append(items, aliased(jsonName, source, source))
+			continue
+		}
+
+		children, err := selectItems(fieldStruct, visiting)
+		if err != nil {
+			return nil, err
+		}
+		if len(children) == 0 {
+			children = []string{"*"}
+		}
+		item := aliased(jsonName, source, strings.Split(source, "!")[0])
+		for _, option := range tag[1:] {
+			if option == "inner" {
+				item += "!inner"
+			}
+		}
+		items = append(items, item+"("+strings.Join(children, ",")+")")
+	}
+	return items, nil
+}
+
+// aliased renames source to jsonName in the response when the returned name would differ
+func aliased(jsonName, source, returnedName string) string {
+	if strings.Contains(source, ":") || jsonName == returnedName {
+		return source
+	}
+	return jsonName + ":" + source
+}
@@ -0,0 +1,146 @@
+package postgrest
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type tagItem struct {
+	ID    int             `json:"id"`
+	Attrs json.RawMessage `json:"attrs"`
+}
+
+type tagOrder struct {
+	ID        int       `json:"id"`
+	Total     float64   `json:"total" postgrest:"amount"`
+	CreatedAt time.Time `json:"created_at"`
+	Items     []tagItem `json:"items"`
+}
+
+type tagTimestamps struct {
+	UpdatedAt *time.Time `json:"updated_at"`
+}
+
+type tagUser struct {
+	tagTimestamps
+	ID        int               `json:"id"`
+	FullName  string            `json:"full_name" postgrest:"full_name:name"`
+	Email     string            `json:"email,omitempty"`
+	Meta      map[string]string `json:"meta"`
+	Orders    []*tagOrder       `json:"orders" postgrest:"orders!orders_user_id_fkey,inner"`
+	Manager   *tagManager       `json:"manager" postgrest:"users!manager_id"`
+	Password  string            `json:"-"`
+	Computed  string            `json:"computed" postgrest:"-"`
+	NoTag     bool
+	unexposed string
+}
+
+type tagManager struct {
+	ID int `json:"id"`
+}
+
+type tagMeta struct {
+	Color string `json:"color"`
+}
+
+type tagVersion struct {
+	Major, Minor int
+}
+
+func (v *tagVersion) UnmarshalText(text []byte) error {
+	_, err := fmt.Sscanf(string(text), "%d.%d", &v.Major, &v.Minor)
+	return err
+}
+
+type tagProfile struct {
+	ID      int            `json:"id"`
+	Meta    tagMeta        `json:"meta" postgrest:",column"`
+	Options *tagMeta       `json:"options" postgrest:"settings,column"`
+	Nick    sql.NullString `json:"nick"`
+	Version tagVersion     `json:"version"`
+	Manager tagManager     `json:"manager"`
+}
+
+type tagNode struct {
+	ID       int        `json:"id"`
+	Children []*tagNode `json:"children"`
+}
+
+func TestSelectFor(t *testing.T) {
+	t.Parallel()
+
+	expected := "updated_at,id,full_name:name,email,meta," +
+		"orders!orders_user_id_fkey!inner(id,total:amount,created_at,items(id,attrs))," +
+		"manager:users!manager_id(id),NoTag"
+	for _, target := range []interface{}{tagUser{}, &tagUser{}, &[]tagUser{}, []*tagUser{}} {
+		selectStr, err := SelectFor(target)
+		if err != nil {
+			t.Errorf("SelectFor returned unexpected error: %v", err)
+		}
+		if selectStr != expected {
+			t.Errorf("SelectFor returned unexpected select:\nExpected: %s\nGot: %s", expected, selectStr)
+		}
+	}
+
+	for _, target := range []interface{}{nil, 1, &map[string]string{}, []byte{}, &time.Time{}} {
+		if _, err := SelectFor(target); err == nil || err.Error() != errInvalidSelectTarget.Error() {
+			t.Errorf("SelectFor returned unexpected error:\nExpected: %v\nGot: %v", errInvalidSelectTarget, err)
+		}
+	}
+
+	expectedError := "postgrest error: recursive type postgrest.tagNode in select target"
+	if _, err := SelectFor(&tagNode{}); err == nil || err.Error() != expectedError {
+		t.Errorf("SelectFor returned unexpected error:\nExpected: %v\nGot: %v", expectedError, err)
+	}
+}
+
+func TestSelectForColumns(t *testing.T) {
+	t.Parallel()
+
+	expected := "id,meta,options:settings,nick,version,manager(id)"
+	if selectStr, err := SelectFor(&[]tagProfile{}); err != nil || selectStr != expected {
+		t.Errorf("SelectFor returned unexpected select:\nExpected: %s\nGot: %s %v", expected, selectStr, err)
+	}
+
+	for _, target := range []interface{}{&sql.NullString{}, &tagVersion{}} {
+		if _, err := SelectFor(target); err == nil || err.Error() != errInvalidSelectTarget.Error() {
+			t.Errorf("SelectFor returned unexpected error:\nExpected: %v\nGot: %v", errInvalidSelectTarget, err)
+		}
+	}
+}
+
+func TestQuerySelectFor(t *testing.T) {
+	t.Parallel()
+
+	var selects []string
+	tagServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		selects = append(selects, r.URL.Query().Get("select"))
+		fmt.Fprint(w, `[{"id":1}]`)
+	}))
+	defer tagServer.Close()
+
+	testAgent := newTestAgent(tagServer.URL)
+	managers := []tagManager{}
+	if _, err := testAgent.From("users").GetJSON(&managers); err != nil {
+		t.Errorf("GetJSON returned unexpected error: %v", err)
+	}
+	if _, err := testAgent.From("users").Select("*").GetJSON(&managers); err != nil {
+		t.Errorf("GetJSON returned unexpected error: %v", err)
+	}
+	if _, err := testAgent.From("users").SelectFor(&managers).Get(); err != nil {
+		t.Errorf("Get returned unexpected error: %v", err)
+	}
+	expected := []string{"id", "*", "id"}
+	if fmt.Sprint(selects) != fmt.Sprint(expected) {
+		t.Errorf("GetJSON requested unexpected select:\nExpected: %v\nGot: %v", expected, selects)
+	}
+
+	if _, err := testAgent.From("users").SelectFor(1).GetJSON(&managers); err == nil || err.Error() != errInvalidSelectTarget.Error() {
+		t.Errorf("GetJSON returned unexpected error:\nExpected: %v\nGot: %v", errInvalidSelectTarget, err)
+	}
+}
@@ -0,0 +1,96 @@
+package postgrest
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultTokenRefreshRatio is the fraction of Config.Timeout after which cached tokens are signed again
+const defaultTokenRefreshRatio = 0.5
+
+// maxTokenCacheEntries bounds the number of cached tokens, e.g: one per impersonated user.
+// The cache is emptied when it is full.
+const maxTokenCacheEntries = 4096
+
+// TokenCacheStats contains the number of requests served by cached and newly signed tokens
+type TokenCacheStats struct {
+	Hits   uint64
+	Misses uint64
+}
+
+// tokenCache caches signed tokens by key until their refresh time
+type tokenCache struct {
+	hits     uint64
+	misses   uint64
+	lifetime time.Duration
+	mu       sync.Mutex
+	entries  map[string]*tokenEntry
+}
+
+// tokenEntry is a cached token. Its mutex ensures that only one goroutine refreshes the token.
+type tokenEntry struct {
+	mu        sync.Mutex
+	tokenStr  string
+	refreshAt time.Time
+}
+
+// newTokenCache returns a tokenCache reusing tokens for the given lifetime
+func newTokenCache(lifetime time.Duration) *tokenCache {
+	return &tokenCache{lifetime: lifetime, entries: map[string]*tokenEntry{}}
+}
+
+// get returns the cached token for key, calling sign to create it if it is missing or due for refresh
+func (c *tokenCache) get(key string, sign func() (string, error)) (string, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	if !ok {
+		if len(c.entries) >= maxTokenCacheEntries {
+			c.entries = map[string]*tokenEntry{}
+		}
+		entry = &tokenEntry{}
+		c.entries[key] = entry
+	}
+	c.mu.Unlock()
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	now := time.Now()
+	if entry.tokenStr != "" && now.Before(entry.refreshAt) {
+		atomic.AddUint64(&c.hits, 1)
+		return entry.tokenStr, nil
+	}
+	atomic.AddUint64(&c.misses, 1)
+	tokenStr, err := sign()
+	if err != nil {
+		return tokenStr, err
+	}
+	entry.tokenStr = tokenStr
+	entry.refreshAt = now.Add(c.lifetime)
+	return tokenStr, nil
+}
+
+// stats returns the hit and miss counts of the cache
+func (c *tokenCache) stats() TokenCacheStats {
+	return TokenCacheStats{Hits: atomic.LoadUint64(&c.hits), Misses: atomic.LoadUint64(&c.misses)}
+}
+
+// TokenCacheStats returns the number of requests that reused a cached token and that signed a new one
+func (agent *Agent) TokenCacheStats() TokenCacheStats {
+	if agent.tokens == nil {
+		return TokenCacheStats{}
+	}
+	return agent.tokens.stats()
+}
+
+// tokenLifetime returns how long signed tokens are reused for the given config
+func tokenLifetime(config *Config) (time.Duration, error) {
+	ratio := config.TokenRefreshRatio
+	if ratio == 0 {
+		ratio = defaultTokenRefreshRatio
+	}
+	if ratio < 0 || ratio >= 1 {
+		return 0, errInvalidTokenRefreshRatio
+	}
+	return time.Duration(ratio * float64(config.Timeout*time.Second)), nil
+}
@@ -0,0 +1,123 @@
+package postgrest
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTokenCache(t *testing.T) {
+	t.Parallel()
+
+	var signed uint64
+	sign := func() (string, error) {
+		atomic.AddUint64(&signed, 1)
+		return "Bearer token", nil
+	}
+	cache := newTokenCache(time.Hour)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if tokenStr, err := cache.get("role", sign); err != nil || tokenStr != "Bearer token" {
+				t.Errorf("get returned unexpected token:\nExpected: %s\nGot: %s %v", "Bearer token", tokenStr, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if signed != 1 {
+		t.Errorf("get signed unexpected number of tokens:\nExpected: %d\nGot: %d", 1, signed)
+	}
+	if stats := cache.stats(); stats != (TokenCacheStats{Hits: 19, Misses: 1}) {
+		t.Errorf("stats returned unexpected counts:\nExpected: %v\nGot: %v", TokenCacheStats{Hits: 19, Misses: 1}, stats)
+	}
+
+	cache.get("other", sign)
+	if signed != 2 {
+		t.Errorf("get signed unexpected number of tokens:\nExpected: %d\nGot: %d", 2, signed)
+	}
+
+	expired := newTokenCache(0)
+	expired.get("role", sign)
+	expired.get("role", sign)
+	if signed != 4 {
+		t.Errorf("get signed unexpected number of tokens:\nExpected: %d\nGot: %d", 4, signed)
+	}
+
+	errSign := errors.New("sign error")
+	failing := newTokenCache(time.Hour)
+	for i := 0; i < 2; i++ {
+		if _, err := failing.get("role", func() (string, error) { return "", errSign }); err != errSign {
+			t.Errorf("get returned unexpected error:\nExpected: %v\nGot: %v", errSign, err)
+		}
+	}
+	if stats := failing.stats(); stats.Misses != 2 {
+		t.Errorf("get cached a failed token:\nExpected: %d misses\nGot: %d", 2, stats.Misses)
+	}
+}
+
+func TestAgentTokenCache(t *testing.T) {
+	t.Parallel()
+
+	var signed uint64
+	generator := func(claims interface{}, secret string) (string, error) {
+		atomic.AddUint64(&signed, 1)
+		return secret, nil
+	}
+	config := &Config{
+		MasterBaseURL: server.URL,
+		MasterRole:    "master",
+		MasterSecret:  "master_secret",
+		SlaveBaseURL:  server.URL,
+		SlaveRole:     "slave",
+		SlaveSecret:   "slave_secret",
+		Timeout:       60,
+	}
+	testAgent, err := NewAgent(config, &http.Client{}, generator)
+	if err != nil {
+		t.Fatalf("NewAgent returned unexpected error: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if tokenStr, _ := testAgent.generateReadTokenStr(); tokenStr != "Bearer slave_secret" {
+			t.Errorf("generateReadTokenStr returned unexpected token:\nExpected: %s\nGot: %s", "Bearer slave_secret", tokenStr)
+		}
+		if tokenStr, _ := testAgent.generateWriteTokenStr(); tokenStr != "Bearer master_secret" {
+			t.Errorf("generateWriteTokenStr returned unexpected token:\nExpected: %s\nGot: %s", "Bearer master_secret", tokenStr)
+		}
+	}
+	if signed != 2 {
+		t.Errorf("agent signed unexpected number of tokens:\nExpected: %d\nGot: %d", 2, signed)
+	}
+	if stats := testAgent.TokenCacheStats(); stats != (TokenCacheStats{Hits: 4, Misses: 2}) {
+		t.Errorf("TokenCacheStats returned unexpected counts:\nExpected: %v\nGot: %v", TokenCacheStats{Hits: 4, Misses: 2}, stats)
+	}
+}
+
+func TestTokenLifetime(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		ratio    float64
+		expected time.Duration
+		err      error
+	}{
+		{0, 30 * time.Second, nil},
+		{0.25, 15 * time.Second, nil},
+		{0.75, 45 * time.Second, nil},
+		{1, 0, errInvalidTokenRefreshRatio},
+		{-0.5, 0, errInvalidTokenRefreshRatio},
+		{1.5, 0, errInvalidTokenRefreshRatio},
+	}
+	for _, test := range tests {
+		lifetime, err := tokenLifetime(&Config{Timeout: 60, TokenRefreshRatio: test.ratio})
+		if lifetime != test.expected || err != test.err {
+			t.Errorf("tokenLifetime returned unexpected lifetime for ratio %v:\nExpected: %v %v\nGot: %v %v",
+				test.ratio, test.expected, test.err, lifetime, err)
+		}
+	}
+}
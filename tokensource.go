@@ -0,0 +1,255 @@
+package postgrest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"hash"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TokenSource signs the claims of a request, e.g: its role, into a JSON Web Token
+type TokenSource interface {
+	Token(claims *Claims) (string, error)
+}
+
+// SecretTokenSource is a TokenSource signing the claims with a secret using a JWTGenerator
+type SecretTokenSource struct {
+	secret      string
+	generateJWT JWTGenerator
+}
+
+// NewSecretTokenSource returns a SecretTokenSource signing the claims with secret.
+// When jwtGenerator is nil tokens are signed with HS256.
+func NewSecretTokenSource(secret string, jwtGenerator JWTGenerator) *SecretTokenSource {
+	if jwtGenerator == nil {
+		jwtGenerator = HS256
+	}
+	return &SecretTokenSource{secret: secret, generateJWT: jwtGenerator}
+}
+
+// Token signs the claims with the secret
+func (s *SecretTokenSource) Token(claims *Claims) (string, error) {
+	return s.generateJWT(claims, s.secret)
+}
+
+// KeyTokenSource is a TokenSource signing the claims with a private key identified by a kid header
+type KeyTokenSource struct {
+	key *signingKey
+}
+
+// NewKeyTokenSource returns a KeyTokenSource for a PEM encoded RSA (RS256) or P-256 EC (ES256) private key.
+// kid is set in the token header, unless empty, for postgREST to select the matching key of its JWKS.
+func NewKeyTokenSource(pemKey, kid string) (*KeyTokenSource, error) {
+	key, err := parsePrivateKey(pemKey)
+	if err != nil {
+		return nil, err
+	}
+	signingKey, err := newSigningKey(key, kid)
+	if err != nil {
+		return nil, err
+	}
+	return &KeyTokenSource{key: signingKey}, nil
+}
+
+// Token signs the claims with the private key
+func (s *KeyTokenSource) Token(claims *Claims) (string, error) {
+	return s.key.token(claims)
+}
+
+// JWKSTokenSource is a TokenSource signing the claims with a key of a local JSON Web Key Set file.
+// The file is reloaded when its modification time changes, which allows rotating keys without
+// restarting. The first key of the set with private parameters, a supported type and a "sig" or
+// empty use signs the tokens, so a new key is rotated in by adding it at the top of the set.
+type JWKSTokenSource struct {
+	path    string
+	mu      sync.Mutex
+	modTime time.Time
+	key     *signingKey
+}
+
+// NewJWKSTokenSource returns a JWKSTokenSource for the JWKS file at path
+func NewJWKSTokenSource(path string) (*JWKSTokenSource, error) {
+	s := &JWKSTokenSource{path: path}
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Token signs the claims with the current signing key of the JWKS file.
+// When the changed file cannot be loaded the previous key keeps being used.
+func (s *JWKSTokenSource) Token(claims *Claims) (string, error) {
+	s.mu.Lock()
+	s.reload()
+	key := s.key
+	s.mu.Unlock()
+	if key == nil {
+		return "", errMissingSigningKey
+	}
+	return key.token(claims)
+}
+
+// reload loads the signing key of the JWKS file if it was modified since it was last loaded
+func (s *JWKSTokenSource) reload() error {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return err
+	}
+	if s.key != nil && info.ModTime().Equal(s.modTime) {
+		return nil
+	}
+	data, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	key, err := parseJWKS(data)
+	if err != nil {
+		return err
+	}
+	s.key, s.modTime = key, info.ModTime()
+	return nil
+}
+
+// signingKey is a key signing tokens with the algorithm alg
+type signingKey struct {
+	alg  string
+	kid  string
+	sign signer
+}
+
+// token signs the claims with the key
+func (k *signingKey) token(claims *Claims) (string, error) {
+	return signJWT(k.alg, k.kid, claims, k.sign)
+}
+
+// newSigningKey returns the signingKey of an RSA or P-256 EC private key
+func newSigningKey(key interface{}, kid string) (*signingKey, error) {
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		return &signingKey{alg: "RS256", kid: kid, sign: rsaSigner(key)}, nil
+	case *ecdsa.PrivateKey:
+		if key.Curve == elliptic.P256() {
+			return &signingKey{alg: "ES256", kid: kid, sign: ecdsaSigner(key)}, nil
+		}
+	}
+	return nil, errInvalidPrivateKey
+}
+
+// jwk is a JSON Web Key with the parameters of oct, RSA and EC private keys
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv"`
+	K   string `json:"k"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	D   string `json:"d"`
+	P   string `json:"p"`
+	Q   string `json:"q"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// parseJWKS returns the signing key of a JSON Web Key Set
+func parseJWKS(data []byte) (*signingKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+	for _, key := range set.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		if signingKey := key.signingKey(); signingKey != nil {
+			return signingKey, nil
+		}
+	}
+	return nil, errMissingSigningKey
+}
+
+// signingKey returns the signingKey of a private JSON Web Key or nil if it is not supported
+func (key jwk) signingKey() *signingKey {
+	switch key.Kty {
+	case "oct":
+		hashes := map[string]func() hash.Hash{"": sha256.New, "HS256": sha256.New, "HS384": sha512.New384, "HS512": sha512.New}
+		h, ok := hashes[key.Alg]
+		secret, err := decodeJWKParam(key.K)
+		if !ok || err != nil || len(secret) == 0 {
+			return nil
+		}
+		alg := key.Alg
+		if alg == "" {
+			alg = "HS256"
+		}
+		return &signingKey{alg: alg, kid: key.Kid, sign: hmacSigner(h, secret)}
+	case "RSA":
+		if key.Alg != "" && key.Alg != "RS256" {
+			return nil
+		}
+		params, err := decodeJWKInts(key.N, key.E, key.D, key.P, key.Q)
+		if err != nil || !params[1].IsInt64() {
+			return nil
+		}
+		privateKey := &rsa.PrivateKey{
+			PublicKey: rsa.PublicKey{N: params[0], E: int(params[1].Int64())},
+			D:         params[2],
+			Primes:    []*big.Int{params[3], params[4]},
+		}
+		if privateKey.Validate() != nil {
+			return nil
+		}
+		privateKey.Precompute()
+		signingKey, _ := newSigningKey(privateKey, key.Kid)
+		return signingKey
+	case "EC":
+		if key.Crv != "P-256" || (key.Alg != "" && key.Alg != "ES256") {
+			return nil
+		}
+		params, err := decodeJWKInts(key.X, key.Y, key.D)
+		if err != nil || !elliptic.P256().IsOnCurve(params[0], params[1]) {
+			return nil
+		}
+		privateKey := &ecdsa.PrivateKey{
+			PublicKey: ecdsa.PublicKey{Curve: elliptic.P256(), X: params[0], Y: params[1]},
+			D:         params[2],
+		}
+		signingKey, _ := newSigningKey(privateKey, key.Kid)
+		return signingKey
+	}
+	return nil
+}
+
+// decodeJWKInts decodes base64url encoded JSON Web Key integer parameters, all of which are required
+func decodeJWKInts(params ...string) ([]*big.Int, error) {
+	ints := make([]*big.Int, len(params))
+	for i, param := range params {
+		b, err := decodeJWKParam(param)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) == 0 {
+			return nil, errMissingSigningKey
+		}
+		ints[i] = new(big.Int).SetBytes(b)
+	}
+	return ints, nil
+}
+
+// decodeJWKParam decodes a base64url encoded JSON Web Key parameter
+func decodeJWKParam(param string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(param, "="))
+}
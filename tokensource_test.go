@@ -0,0 +1,212 @@
+package postgrest
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ecJWK returns the JSON Web Key of an EC private key
+func ecJWK(key *ecdsa.PrivateKey, kid string) string {
+	encode := func(i *big.Int) string { return base64.RawURLEncoding.EncodeToString(i.Bytes()) }
+	return fmt.Sprintf(`{"kty":"EC","crv":"P-256","kid":"%s","x":"%s","y":"%s","d":"%s"}`,
+		kid, encode(key.X), encode(key.Y), encode(key.D))
+}
+
+// verifyES256 ensures that token is signed by key with the given kid header
+func verifyES256(t *testing.T, token string, key *ecdsa.PrivateKey, kid string) {
+	header, _, signingInput, signature := decodeJWT(t, token)
+	expectedHeader := map[string]string{"alg": "ES256", "typ": "JWT", "kid": kid}
+	if !reflect.DeepEqual(header, expectedHeader) {
+		t.Errorf("token has unexpected header:\nExpected: %v\nGot: %v", expectedHeader, header)
+	}
+	digest := sha256.Sum256([]byte(signingInput))
+	r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+	if !ecdsa.Verify(&key.PublicKey, digest[:], r, s) {
+		t.Errorf("token has invalid signature for kid %s", kid)
+	}
+}
+
+func TestSecretTokenSource(t *testing.T) {
+	t.Parallel()
+
+	claims := generateClaims("role", &Config{Timeout: 5})
+	expected, _ := HS256(claims, "secret")
+	if token, err := NewSecretTokenSource("secret", nil).Token(claims); err != nil || token != expected {
+		t.Errorf("Token returned unexpected token:\nExpected: %s\nGot: %s %v", expected, token, err)
+	}
+	generator := func(_ interface{}, secret string) (string, error) { return "signed with " + secret, nil }
+	if token, _ := NewSecretTokenSource("secret", generator).Token(claims); token != "signed with secret" {
+		t.Errorf("Token returned unexpected token:\nExpected: %s\nGot: %s", "signed with secret", token)
+	}
+}
+
+func TestKeyTokenSource(t *testing.T) {
+	t.Parallel()
+
+	claims := generateClaims("role", &Config{Timeout: 5})
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	source, err := NewKeyTokenSource(string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})), "rsa-1")
+	if err != nil {
+		t.Fatalf("NewKeyTokenSource returned unexpected error: %v", err)
+	}
+	token, err := source.Token(claims)
+	if err != nil {
+		t.Fatalf("Token returned unexpected error: %v", err)
+	}
+	header, decoded, signingInput, signature := decodeJWT(t, token)
+	if header["alg"] != "RS256" || header["kid"] != "rsa-1" || !reflect.DeepEqual(decoded, claims) {
+		t.Errorf("Token returned unexpected token:\nExpected: %s %s %v\nGot: %s %s %v", "RS256", "rsa-1", claims, header["alg"], header["kid"], decoded)
+	}
+	digest := sha256.Sum256([]byte(signingInput))
+	if err := rsa.VerifyPKCS1v15(&rsaKey.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+		t.Errorf("Token returned invalid signature: %v", err)
+	}
+
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	der, _ := x509.MarshalECPrivateKey(ecKey)
+	source, err = NewKeyTokenSource(string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})), "ec-1")
+	if err != nil {
+		t.Fatalf("NewKeyTokenSource returned unexpected error: %v", err)
+	}
+	token, _ = source.Token(claims)
+	verifyES256(t, token, ecKey, "ec-1")
+
+	p384Key, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	der, _ = x509.MarshalECPrivateKey(p384Key)
+	for _, pemKey := range []string{"secret", string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}))} {
+		if _, err := NewKeyTokenSource(pemKey, ""); err != errInvalidPrivateKey {
+			t.Errorf("NewKeyTokenSource returned unexpected error:\nExpected: %v\nGot: %v", errInvalidPrivateKey, err)
+		}
+	}
+}
+
+func TestJWKSTokenSource(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "jwks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "jwks.json")
+	modTime := time.Now().Add(-time.Hour)
+	writeJWKS := func(keys ...string) {
+		ioutil.WriteFile(path, []byte(`{"keys":[`+strings.Join(keys, ",")+`]}`), 0600)
+		modTime = modTime.Add(time.Minute)
+		os.Chtimes(path, modTime, modTime)
+	}
+	claims := generateClaims("role", &Config{Timeout: 5})
+
+	oldKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	newKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	writeJWKS(`{"kty":"EC","crv":"P-256","kid":"public","x":"AQ","y":"AQ"}`, `{"kty":"oct","use":"enc","k":"c2VjcmV0"}`, ecJWK(oldKey, "old"))
+	source, err := NewJWKSTokenSource(path)
+	if err != nil {
+		t.Fatalf("NewJWKSTokenSource returned unexpected error: %v", err)
+	}
+	token, _ := source.Token(claims)
+	verifyES256(t, token, oldKey, "old")
+
+	writeJWKS(ecJWK(newKey, "new"), ecJWK(oldKey, "old"))
+	token, _ = source.Token(claims)
+	verifyES256(t, token, newKey, "new")
+
+	writeJWKS(`{"keys":`)
+	token, err = source.Token(claims)
+	if err != nil {
+		t.Errorf("Token returned unexpected error: %v", err)
+	}
+	verifyES256(t, token, newKey, "new")
+
+	writeJWKS(`{"kty":"oct","kid":"hmac","alg":"HS384","k":"c2VjcmV0"}`)
+	token, _ = source.Token(claims)
+	header, _, signingInput, signature := decodeJWT(t, token)
+	mac := hmac.New(sha512.New384, []byte("secret"))
+	mac.Write([]byte(signingInput))
+	if header["alg"] != "HS384" || header["kid"] != "hmac" || !hmac.Equal(signature, mac.Sum(nil)) {
+		t.Errorf("Token returned unexpected token:\nExpected: %s %s\nGot: %s %s", "HS384", "hmac", header["alg"], header["kid"])
+	}
+
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	encode := func(i *big.Int) string { return base64.RawURLEncoding.EncodeToString(i.Bytes()) }
+	writeJWKS(fmt.Sprintf(`{"kty":"RSA","kid":"rsa","n":"%s","e":"AQAB","d":"%s","p":"%s","q":"%s"}`,
+		encode(rsaKey.N), encode(rsaKey.D), encode(rsaKey.Primes[0]), encode(rsaKey.Primes[1])))
+	token, _ = source.Token(claims)
+	header, _, signingInput, signature = decodeJWT(t, token)
+	digest := sha256.Sum256([]byte(signingInput))
+	if header["kid"] != "rsa" || rsa.VerifyPKCS1v15(&rsaKey.PublicKey, crypto.SHA256, digest[:], signature) != nil {
+		t.Errorf("Token returned invalid RS256 token with kid %s", header["kid"])
+	}
+
+	writeJWKS(`{"kty":"EC","crv":"P-384","x":"AQ","y":"AQ","d":"AQ"}`)
+	if _, err := NewJWKSTokenSource(path); err != errMissingSigningKey {
+		t.Errorf("NewJWKSTokenSource returned unexpected error:\nExpected: %v\nGot: %v", errMissingSigningKey, err)
+	}
+	if _, err := NewJWKSTokenSource(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("NewJWKSTokenSource returned no error for a missing file")
+	}
+}
+
+func TestNewAgentWithTokenSources(t *testing.T) {
+	t.Parallel()
+
+	var tokens []string
+	sourceServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokens = append(tokens, r.Header.Get("Authorization"))
+		w.Write([]byte("[]"))
+	}))
+	defer sourceServer.Close()
+
+	config := &Config{
+		MasterBaseURL: sourceServer.URL,
+		MasterRole:    "master",
+		SlaveBaseURL:  sourceServer.URL,
+		SlaveRole:     "slave",
+		Timeout:       5,
+	}
+	master := NewSecretTokenSource("master_secret", func(claims interface{}, secret string) (string, error) {
+		return "master:" + claims.(*Claims).Role, nil
+	})
+	slave := NewSecretTokenSource("slave_secret", func(claims interface{}, secret string) (string, error) {
+		return "slave:" + claims.(*Claims).Role, nil
+	})
+
+	if _, err := NewAgentWithTokenSources(config, &http.Client{}, master, nil); err != errMissingTokenSource {
+		t.Errorf("NewAgentWithTokenSources returned unexpected error:\nExpected: %v\nGot: %v", errMissingTokenSource, err)
+	}
+	if _, err := NewAgentWithTokenSources(&Config{}, &http.Client{}, master, slave); err == nil || strings.Contains(err.Error(), "Secret") {
+		t.Errorf("NewAgentWithTokenSources returned unexpected error: %v", err)
+	}
+	testAgent, err := NewAgentWithTokenSources(config, &http.Client{}, master, slave)
+	if err != nil {
+		t.Fatalf("NewAgentWithTokenSources returned unexpected error: %v", err)
+	}
+	testAgent.Get("test_table", nil)
+	testAgent.Post("test_table", strings.NewReader("{}"))
+	testAgent.Impersonate("web_user", nil).Get("test_table", nil)
+
+	expected := []string{"Bearer slave:slave", "Bearer master:master", "Bearer slave:web_user"}
+	if !reflect.DeepEqual(tokens, expected) {
+		t.Errorf("agent sent unexpected Authorization headers:\nExpected: %v\nGot: %v", expected, tokens)
+	}
+}
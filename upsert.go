@@ -0,0 +1,132 @@
+package postgrest
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Resolution specifies how postgREST resolves duplicate rows when upserting
+type Resolution string
+
+// conflict resolutions supported by postgREST
+const (
+	MergeDuplicates  Resolution = "merge-duplicates"
+	IgnoreDuplicates Resolution = "ignore-duplicates"
+)
+
+// preferHeader returns a header containing the given postgREST preferences
+func preferHeader(preferences ...string) http.Header {
+	return http.Header{"Prefer": {strings.Join(preferences, ",")}}
+}
+
+// upsert makes an HTTP POST request with the given resolution to the postgREST master service
+func (agent *Agent) upsert(ctx context.Context, table string, body io.Reader, preferences []string, onConflict []string) (*http.Response, error) {
+	var query *url.Values
+	if len(onConflict) > 0 {
+		query = &url.Values{"on_conflict": {strings.Join(onConflict, ",")}}
+	}
+	return agent.send(ctx, http.MethodPost, table, query, preferHeader(preferences...), body)
+}
+
+// Upsert makes an HTTP POST request to the postgREST master service inserting the rows in body into table
+// and resolving conflicts on the primary key, or on the unique onConflict columns if given.
+func (agent *Agent) Upsert(table string, body io.Reader, resolution Resolution, onConflict ...string) (*http.Response, error) {
+	return agent.UpsertContext(context.Background(), table, body, resolution, onConflict...)
+}
+
+// UpsertContext is Upsert with the given context attached to the request
+func (agent *Agent) UpsertContext(ctx context.Context, table string, body io.Reader, resolution Resolution, onConflict ...string) (*http.Response, error) {
+	return agent.upsert(ctx, table, body, []string{"resolution=" + string(resolution)}, onConflict)
+}
+
+// UpsertJSON makes an HTTP POST request to the postgREST master service upserting the JSON encoded payload
+// and unmarshals the resulting rows into the given target interface if it is not nil
+// Returns error if the response status code is not inclusively between 200 and 299
+func (agent *Agent) UpsertJSON(table string, payload interface{}, target interface{}, resolution Resolution, onConflict ...string) (int, error) {
+	return agent.UpsertJSONContext(context.Background(), table, payload, target, resolution, onConflict...)
+}
+
+// UpsertJSONContext is UpsertJSON with the given context attached to the request
+func (agent *Agent) UpsertJSONContext(ctx context.Context, table string, payload interface{}, target interface{}, resolution Resolution, onConflict ...string) (int, error) {
+	body, err := jsonEncode(payload)
+	if err != nil {
+		return 0, err
+	}
+	preferences := []string{"resolution=" + string(resolution)}
+	if target != nil {
+		preferences = append(preferences, "return=representation")
+	}
+	response, err := agent.upsert(ctx, table, body, preferences, onConflict)
+	if err != nil {
+		return 0, err
+	}
+	return unmarshalResponse(response, target)
+}
+
+// Put makes an HTTP PUT request to the postgREST master service upserting the single row in body.
+// query must filter every primary key column with `eq` and match the values in body.
+func (agent *Agent) Put(table string, query *url.Values, body io.Reader) (*http.Response, error) {
+	return agent.PutContext(context.Background(), table, query, body)
+}
+
+// PutContext is Put with the given context attached to the request
+func (agent *Agent) PutContext(ctx context.Context, table string, query *url.Values, body io.Reader) (*http.Response, error) {
+	return agent.send(ctx, http.MethodPut, table, query, nil, body)
+}
+
+// PutJSON makes an HTTP PUT request to the postgREST master service upserting the JSON encoded payload
+// and unmarshals the resulting row into the given target interface if it is not nil
+// Returns error if the response status code is not inclusively between 200 and 299
+func (agent *Agent) PutJSON(table string, query *url.Values, payload interface{}, target interface{}) (int, error) {
+	return agent.PutJSONContext(context.Background(), table, query, payload, target)
+}
+
+// PutJSONContext is PutJSON with the given context attached to the request
+func (agent *Agent) PutJSONContext(ctx context.Context, table string, query *url.Values, payload interface{}, target interface{}) (int, error) {
+	body, err := jsonEncode(payload)
+	if err != nil {
+		return 0, err
+	}
+	var header http.Header
+	if target != nil {
+		header = preferHeader("return=representation")
+	}
+	response, err := agent.send(ctx, http.MethodPut, table, query, header, body)
+	if err != nil {
+		return 0, err
+	}
+	return unmarshalResponse(response, target)
+}
+
+// Put makes an HTTP PUT request upserting the single row matched by the query to the postgREST master service
+func (q *Query) Put(body io.Reader) (*http.Response, error) {
+	if q.err != nil {
+		return nil, q.err
+	}
+	return q.agent.send(q.ctx, http.MethodPut, q.table, q.Values(), q.requestHeader(), body)
+}
+
+// PutJSON upserts the JSON encoded payload as the single row matched by the query
+// and unmarshals the resulting row into the given target interface if it is not nil
+func (q *Query) PutJSON(payload interface{}, target interface{}) (int, error) {
+	if q.err != nil {
+		return 0, q.err
+	}
+	body, err := jsonEncode(payload)
+	if err != nil {
+		return 0, err
+	}
+	header := q.requestHeader()
+	if target != nil {
+		header = cloneHeader(header)
+		header.Add("Prefer", "return=representation")
+	}
+	response, err := q.agent.send(q.ctx, http.MethodPut, q.table, q.Values(), header, body)
+	if err != nil {
+		return 0, err
+	}
+	return unmarshalResponse(response, target)
+}
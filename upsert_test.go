@@ -0,0 +1,109 @@
+package postgrest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUpsert(t *testing.T) {
+	t.Parallel()
+
+	upsertServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("X-Method", r.Method)
+		w.Header().Set("X-Prefer", strings.Join(r.Header["Prefer"], ", "))
+		w.Header().Set("X-Query", r.URL.RawQuery)
+		if strings.Contains(r.Header.Get("Prefer"), "return=representation") {
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, string(body))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer upsertServer.Close()
+	testAgent := newTestAgent(upsertServer.URL)
+
+	response, err := testAgent.Upsert("test_table", strings.NewReader(`[{"id":1}]`), IgnoreDuplicates, "email", "tenant_id")
+	if err != nil {
+		t.Errorf("Upsert returned unexpected error: %v", err)
+	}
+	var tests = []struct {
+		header   string
+		expected string
+	}{
+		{"X-Method", http.MethodPost},
+		{"X-Prefer", "resolution=ignore-duplicates"},
+		{"X-Query", "on_conflict=email%2Ctenant_id"},
+	}
+	for _, test := range tests {
+		if got := response.Header.Get(test.header); got != test.expected {
+			t.Errorf("Upsert sent unexpected %s:\nExpected: %s\nGot: %s", test.header, test.expected, got)
+		}
+	}
+
+	objects := []object{}
+	status, err := testAgent.UpsertJSON("test_table", []*object{testObject}, &objects, MergeDuplicates)
+	if err != nil {
+		t.Errorf("UpsertJSON returned unexpected error: %v", err)
+	}
+	if status != http.StatusCreated || !reflect.DeepEqual(objects, []object{*testObject}) {
+		t.Errorf("UpsertJSON returned unexpected results:\nExpected: %d %v\nGot: %d %v", http.StatusCreated, []object{*testObject}, status, objects)
+	}
+
+	status, err = testAgent.UpsertJSON("test_table", testObject, nil, MergeDuplicates)
+	if err != nil || status != http.StatusNoContent {
+		t.Errorf("UpsertJSON returned unexpected results:\nExpected: %d\nGot: %d %v", http.StatusNoContent, status, err)
+	}
+
+	obj := &object{}
+	status, err = testAgent.From("test_table").Eq("id", testObject.ID).PutJSON(testObject, obj)
+	if err != nil {
+		t.Errorf("PutJSON returned unexpected error: %v", err)
+	}
+	if status != http.StatusCreated || !reflect.DeepEqual(obj, testObject) {
+		t.Errorf("PutJSON returned unexpected results:\nExpected: %d %v\nGot: %d %v", http.StatusCreated, testObject, status, obj)
+	}
+
+	putQuery := testAgent.From("test_table").Eq("id", testObject.ID)
+	for i := 0; i < 2; i++ {
+		response, err = putQuery.Put(strings.NewReader(`{"id":1}`))
+		if err != nil {
+			t.Errorf("Put returned unexpected error: %v", err)
+		}
+		if prefer := response.Header.Get("X-Prefer"); prefer != "" {
+			t.Errorf("PutJSON changed the Prefer header of the query:\nExpected: %q\nGot: %q", "", prefer)
+		}
+		obj = &object{}
+		if _, err := putQuery.PutJSON(testObject, obj); err != nil || !reflect.DeepEqual(obj, testObject) {
+			t.Errorf("PutJSON returned unexpected results:\nExpected: %v\nGot: %v %v", testObject, obj, err)
+		}
+	}
+	response, err = putQuery.Get()
+	if err != nil {
+		t.Errorf("Get returned unexpected error: %v", err)
+	}
+	if prefer := response.Header.Get("X-Prefer"); prefer != "" {
+		t.Errorf("PutJSON changed the Prefer header of the query:\nExpected: %q\nGot: %q", "", prefer)
+	}
+	response.Body.Close()
+
+	query := testAgent.From("test_table").Eq("id", testObject.ID).Values()
+	response, err = testAgent.Put("test_table", query, strings.NewReader(`{"id":1}`))
+	if err != nil {
+		t.Errorf("Put returned unexpected error: %v", err)
+	}
+	if response.Header.Get("X-Method") != http.MethodPut || response.Header.Get("X-Query") != "id=eq.12345678900" {
+		t.Errorf("Put sent unexpected request:\nExpected: %s %s\nGot: %s %s", http.MethodPut, "id=eq.12345678900",
+			response.Header.Get("X-Method"), response.Header.Get("X-Query"))
+	}
+
+	status, err = testAgent.PutJSON("test_table", query, testObject, nil)
+	if err != nil || status != http.StatusNoContent {
+		t.Errorf("PutJSON returned unexpected results:\nExpected: %d\nGot: %d %v", http.StatusNoContent, status, err)
+	}
+}